@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// VideoAccess is the access posture of a video in one normalized shape
+type VideoAccess struct {
+	UID               string       `json:"uid"`
+	RequireSignedURLs bool         `json:"requireSignedURLs"`
+	AllowedOrigins    []string     `json:"allowedOrigins"`
+	AnyOrigin         bool         `json:"anyOrigin"`
+	AccessRules       []AccessRule `json:"accessRules"`
+}
+
+// storedAccessRules returns any access rules Cloudflare keeps on the video itself.
+// Rules are normally carried by signed tokens, so this is usually empty.
+func storedAccessRules(video *VideoUploadResponse) []AccessRule {
+	var raw struct {
+		Result struct {
+			AccessRules []AccessRule `json:"accessRules"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(video.Raw, &raw); err != nil {
+		return nil
+	}
+	return raw.Result.AccessRules
+}
+
+// normalizeAccessRules lower-cases types and actions, upper-cases country codes
+// and sorts each rule's lists. Rule order is kept since Cloudflare evaluates it.
+func normalizeAccessRules(rules []AccessRule) []AccessRule {
+	normalized := make([]AccessRule, 0, len(rules))
+	for _, rule := range rules {
+		out := AccessRule{
+			Type:   strings.ToLower(strings.TrimSpace(rule.Type)),
+			Action: strings.ToLower(strings.TrimSpace(rule.Action)),
+		}
+		for _, country := range rule.Country {
+			out.Country = append(out.Country, strings.ToUpper(strings.TrimSpace(country)))
+		}
+		for _, ip := range rule.IP {
+			out.IP = append(out.IP, strings.TrimSpace(ip))
+		}
+		sort.Strings(out.Country)
+		sort.Strings(out.IP)
+		normalized = append(normalized, out)
+	}
+	return normalized
+}
+
+// videoAccess summarizes who may play a video
+func videoAccess(video *VideoUploadResponse) VideoAccess {
+	origins := make([]string, 0, len(video.Result.AllowedOrigins))
+	for _, origin := range video.Result.AllowedOrigins {
+		// Keep entries Cloudflare accepted even if they no longer pass our stricter checks
+		if normalized, err := normalizeOrigin(origin); err == nil {
+			origin = normalized
+		}
+		origins = append(origins, origin)
+	}
+	sort.Strings(origins)
+
+	return VideoAccess{
+		UID:               video.Result.UID,
+		RequireSignedURLs: video.Result.RequireSignedURLs,
+		AllowedOrigins:    origins,
+		AnyOrigin:         len(origins) == 0,
+		AccessRules:       normalizeAccessRules(storedAccessRules(video)),
+	}
+}
+
+func registerAccessRuleRoutes(app *fiber.App, config CloudflareConfig, timeout fiber.Handler) {
+	// Signed URL requirement, allowed origins and stored access rules for auditing
+	app.Get("/api/video/:uid/access-rules", timeout, func(c *fiber.Ctx) error {
+		video, err := fetchVideo(c.UserContext(), config, c.Params("uid"))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !video.Success {
+			return respondCloudflareError(c, "Failed to get video", video.Errors)
+		}
+
+		return respond(c, 200, videoAccess(video))
+	})
+}
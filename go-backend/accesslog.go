@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AccessLogEntry is one structured access log line
+type AccessLogEntry struct {
+	Time       string  `json:"time"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	DurationMS float64 `json:"durationMs"`
+	IP         string  `json:"ip"`
+	Error      string  `json:"error,omitempty"`
+	TraceID    string  `json:"traceId,omitempty"`
+}
+
+// accessLog writes one JSON line per request. Successful GETs are sampled at
+// 1 in sampleRate; errors, non-2xx responses and writes are always logged.
+func accessLog(sampleRate int) fiber.Handler {
+	if sampleRate < 1 {
+		sampleRate = 1
+	}
+	var successfulGets atomic.Uint64
+
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			if fe, ok := err.(*fiber.Error); ok {
+				status = fe.Code
+			}
+		}
+
+		routine := err == nil && c.Method() == fiber.MethodGet && status >= 200 && status < 300
+		if routine && (successfulGets.Add(1)-1)%uint64(sampleRate) != 0 {
+			return err
+		}
+
+		entry := AccessLogEntry{
+			Time:       start.UTC().Format(time.RFC3339Nano),
+			Method:     c.Method(),
+			Path:       c.Path(),
+			Status:     status,
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			IP:         c.IP(),
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+
+		if spanContext := trace.SpanContextFromContext(c.UserContext()); spanContext.HasTraceID() {
+			entry.TraceID = spanContext.TraceID().String()
+		}
+
+		line, _ := json.Marshal(entry)
+		fmt.Println(string(line))
+		return err
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxBatchUIDs caps how many videos a single batch request may ask about
+const maxBatchUIDs = 100
+
+// BatchStatusRequest is the body accepted by the batch status endpoint
+type BatchStatusRequest struct {
+	UIDs []string `json:"uids"`
+}
+
+// BatchStatusEntry holds either the status of one video, shaped like the single
+// status endpoint's, or the error fetching it
+type BatchStatusEntry struct {
+	Result *VideoDTO         `json:"result,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Errors []CloudflareError `json:"errors,omitempty"`
+}
+
+// fetchVideosConcurrently fetches each UID with at most `concurrency` requests in flight
+func fetchVideosConcurrently(ctx context.Context, config CloudflareConfig, uids []string, concurrency int) map[string]BatchStatusEntry {
+	results := make(map[string]BatchStatusEntry, len(uids))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+
+	for _, uid := range uids {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(uid string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			var entry BatchStatusEntry
+			result, err := fetchVideo(ctx, config, uid)
+			switch {
+			case err != nil:
+				entry.Error = err.Error()
+			case !result.Success:
+				entry.Error = "Failed to get video status"
+				entry.Errors = result.Errors
+			default:
+				dto := newVideoDTO(result.Result)
+				// Videos still encoding have no playback URLs yet; leave the bundle out
+				if urls, err := playbackURLs(ctx, config, result.Result); err == nil {
+					dto.URLs = &urls
+				} else if result.Result.RequireSignedURLs {
+					fmt.Printf("Could not sign playback URLs for %s: %v\n", uid, err)
+				}
+				entry.Result = &dto
+			}
+
+			mu.Lock()
+			results[uid] = entry
+			mu.Unlock()
+		}(uid)
+	}
+
+	wg.Wait()
+	return results
+}
+
+func registerBatchRoutes(app *fiber.App, config CloudflareConfig, concurrency int, timeout fiber.Handler) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// Fetch the status of many videos in one round-trip
+	app.Post("/api/videos/status", timeout, requireJSON(), func(c *fiber.Ctx) error {
+		var body BatchStatusRequest
+		if ok, err := bindBody(c, &body); !ok {
+			return err
+		}
+
+		seen := make(map[string]bool, len(body.UIDs))
+		uids := make([]string, 0, len(body.UIDs))
+		for _, uid := range body.UIDs {
+			if uid != "" && !seen[uid] {
+				seen[uid] = true
+				uids = append(uids, uid)
+			}
+		}
+
+		if len(uids) == 0 {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "uids must contain at least one video UID",
+			})
+		}
+		if len(uids) > maxBatchUIDs {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Too many uids in one batch",
+				"limit": maxBatchUIDs,
+			})
+		}
+
+		return respondResult(c, 200, fetchVideosConcurrently(c.UserContext(), config, uids, concurrency), nil)
+	})
+}
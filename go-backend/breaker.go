@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// errCircuitOpen is returned for outbound calls made while the breaker is open
+var errCircuitOpen = errors.New("Cloudflare circuit breaker is open")
+
+// Circuit breaker states
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// CircuitBreaker stops calling Cloudflare after repeated consecutive failures.
+// Once the cooldown has passed a single probe request is let through: success
+// closes the circuit again, failure reopens it for another cooldown.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, state: circuitClosed}
+}
+
+// Allow reports whether a call may go out now, claiming the probe slot when half-open
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = circuitHalfOpen
+		b.probing = true
+		fmt.Println("Circuit breaker half-open, probing Cloudflare")
+		return true
+	case circuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// Success records a healthy response, closing the circuit
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != circuitClosed {
+		fmt.Println("Circuit breaker closed, Cloudflare is responding again")
+	}
+	b.state = circuitClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure records an upstream failure, opening the circuit at the threshold
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	b.failures++
+	if b.state == circuitHalfOpen || (b.state == circuitClosed && b.failures >= b.threshold) {
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+		fmt.Printf("Circuit breaker open after %d consecutive failure(s), pausing Cloudflare calls for %s\n", b.failures, b.cooldown)
+	}
+}
+
+// Abandon releases a probe that ended without telling us anything, such as a
+// request the client cancelled
+func (b *CircuitBreaker) Abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// RetryAfter is how long until the breaker will probe again, or zero if calls are allowed
+func (b *CircuitBreaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != circuitOpen {
+		return 0
+	}
+	if wait := b.cooldown - time.Since(b.openedAt); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// State returns the current state and consecutive failure count
+func (b *CircuitBreaker) State() (string, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, b.failures
+}
+
+// breakerTransport feeds every outbound response into the circuit breaker.
+// Network errors and 5xx responses count as failures; 4xx are the caller's problem.
+type breakerTransport struct {
+	next    http.RoundTripper
+	breaker *CircuitBreaker
+}
+
+func (t breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.breaker.Allow() {
+		return nil, errCircuitOpen
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil && errors.Is(err, context.Canceled):
+		t.breaker.Abandon()
+	case err != nil || resp.StatusCode >= 500:
+		t.breaker.Failure()
+	default:
+		t.breaker.Success()
+	}
+	return resp, err
+}
+
+// requireClosedCircuit answers 503 straight away while the breaker is open.
+// Requests for which next returns true are let through regardless.
+func requireClosedCircuit(b *CircuitBreaker, next func(*fiber.Ctx) bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if next != nil && next(c) {
+			return c.Next()
+		}
+		if wait := b.RetryAfter(); wait > 0 {
+			c.Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return c.Status(503).JSON(fiber.Map{
+				"error": "Cloudflare is unavailable, try again shortly",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// registerReadinessRoutes exposes whether the server can currently serve Cloudflare-backed requests
+func registerReadinessRoutes(app *fiber.App, breaker *CircuitBreaker, health *CredentialHealth) {
+	app.Get("/readyz", func(c *fiber.Ctx) error {
+		state, failures := breaker.State()
+		ready := state != circuitOpen && health.Healthy()
+
+		status := 200
+		if !ready {
+			status = 503
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"ready":               ready,
+			"circuit":             state,
+			"consecutiveFailures": failures,
+			"credentialsValid":    health.Healthy(),
+		})
+	})
+}
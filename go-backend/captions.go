@@ -0,0 +1,585 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// captionHeaderPeek is how much of a caption file is read to check its header:
+// a byte order mark, "WEBVTT" and the character after it
+const captionHeaderPeek = 10
+
+// captionFormOverhead is the room left above the caption limit for the rest of
+// the multipart form: boundaries, part headers and the filename
+const captionFormOverhead = 64 << 10
+
+// captionDeleteConcurrency bounds how many languages are deleted at once when
+// clearing every caption track of a video
+const captionDeleteConcurrency = 4
+
+// languageTag matches BCP 47 tags such as "en", "pt-BR" or "zh-Hant"
+var languageTag = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// srtTimestamp matches an SRT timestamp such as 00:01:02,345
+var srtTimestamp = regexp.MustCompile(`^(\d{1,2}):(\d{2}):(\d{2})[,.](\d{3})$`)
+
+// CaptionResponse represents Cloudflare's response after uploading or generating
+// a caption track
+type CaptionResponse struct {
+	Result   Caption           `json:"result"`
+	Success  bool              `json:"success"`
+	Errors   []CloudflareError `json:"errors"`
+	Messages []string          `json:"messages"`
+}
+
+// Caption is one caption track of a video as listed by Cloudflare
+type Caption struct {
+	Language  string `json:"language"`
+	Label     string `json:"label"`
+	Generated bool   `json:"generated,omitempty"`
+	Status    string `json:"status,omitempty"`
+}
+
+// CaptionListResponse represents Cloudflare's response when listing a video's captions
+type CaptionListResponse struct {
+	Result   []Caption         `json:"result"`
+	Success  bool              `json:"success"`
+	Errors   []CloudflareError `json:"errors"`
+	Messages []string          `json:"messages"`
+}
+
+// captionGenerating is the status of an auto-generated caption track still being produced
+const captionGenerating = "inprogress"
+
+// listCaptions retrieves every caption track of a video
+func listCaptions(ctx context.Context, config CloudflareConfig, uid string) (*CaptionListResponse, error) {
+	return callCloudflare[CaptionListResponse](ctx, config, "GET", "/stream/"+uid+"/captions", nil)
+}
+
+// CaptionParseError reports malformed SRT input with the line it was found on
+type CaptionParseError struct {
+	Line    int
+	Message string
+}
+
+func (e *CaptionParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// parseSRTTimestamp converts an SRT timestamp to milliseconds
+func parseSRTTimestamp(value string) (int, bool) {
+	m := srtTimestamp.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, false
+	}
+	hours, _ := strconv.Atoi(m[1])
+	minutes, _ := strconv.Atoi(m[2])
+	seconds, _ := strconv.Atoi(m[3])
+	millis, _ := strconv.Atoi(m[4])
+	if minutes > 59 || seconds > 59 {
+		return 0, false
+	}
+	return ((hours*60+minutes)*60+seconds)*1000 + millis, true
+}
+
+// formatVTTTimestamp renders milliseconds as HH:MM:SS.mmm
+func formatVTTTimestamp(ms int) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// srtToVTT converts SubRip captions to WebVTT, rejecting cues without a valid
+// timing line or text
+func srtToVTT(srt []byte) ([]byte, error) {
+	srt = bytes.TrimPrefix(srt, []byte("\xef\xbb\xbf"))
+
+	var out bytes.Buffer
+	out.WriteString("WEBVTT\n")
+
+	scanner := bufio.NewScanner(bytes.NewReader(srt))
+	lineNo := 0
+	cues := 0
+	for {
+		// Collect the next block of non-blank lines
+		var block []string
+		start := 0
+		for scanner.Scan() {
+			lineNo++
+			line := strings.TrimRight(scanner.Text(), "\r")
+			if strings.TrimSpace(line) == "" {
+				if len(block) > 0 {
+					break
+				}
+				continue
+			}
+			if len(block) == 0 {
+				start = lineNo
+			}
+			block = append(block, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		if len(block) == 0 {
+			break
+		}
+
+		// The numeric cue index is optional in practice
+		timing, timingLine := 0, start
+		if _, err := strconv.Atoi(strings.TrimSpace(block[0])); err == nil && len(block) > 1 {
+			timing, timingLine = 1, start+1
+		}
+
+		from, to, ok := strings.Cut(block[timing], "-->")
+		if !ok {
+			return nil, &CaptionParseError{timingLine, "expected a timing line like 00:00:01,000 --> 00:00:02,000"}
+		}
+		// Anything after the end timestamp (SRT position hints) is dropped
+		to = strings.Fields(to + " ")[0]
+		startMS, okStart := parseSRTTimestamp(from)
+		endMS, okEnd := parseSRTTimestamp(to)
+		if !okStart || !okEnd {
+			return nil, &CaptionParseError{timingLine, "invalid timestamp"}
+		}
+		if endMS < startMS {
+			return nil, &CaptionParseError{timingLine, "cue ends before it starts"}
+		}
+
+		text := block[timing+1:]
+		if len(text) == 0 {
+			return nil, &CaptionParseError{timingLine, "cue has no text"}
+		}
+
+		out.WriteString("\n")
+		if timing == 1 {
+			out.WriteString(strings.TrimSpace(block[0]) + "\n")
+		}
+		out.WriteString(formatVTTTimestamp(startMS) + " --> " + formatVTTTimestamp(endMS) + "\n")
+		for _, line := range text {
+			out.WriteString(line + "\n")
+		}
+		cues++
+	}
+
+	if cues == 0 {
+		return nil, &CaptionParseError{1, "file contains no cues"}
+	}
+	return out.Bytes(), nil
+}
+
+// isVTT reports whether contents start with a WebVTT header: "WEBVTT", after an
+// optional byte order mark, followed by whitespace or nothing at all
+func isVTT(contents []byte) bool {
+	rest, ok := bytes.CutPrefix(bytes.TrimPrefix(contents, []byte("\xef\xbb\xbf")), []byte("WEBVTT"))
+	if !ok {
+		return false
+	}
+	return len(rest) == 0 || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\n' || rest[0] == '\r'
+}
+
+// deleteCaptionsConcurrently deletes each language's caption track, returning the
+// languages removed and the reason each of the others failed
+func deleteCaptionsConcurrently(ctx context.Context, config CloudflareConfig, uid string, languages []string) ([]string, map[string]string) {
+	deleted := []string{}
+	failed := map[string]string{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, captionDeleteConcurrency)
+
+	for _, lang := range languages {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(lang string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			status, err := deleteResource(ctx, config, "/stream/"+uid+"/captions/"+lang)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				failed[lang] = err.Error()
+			// Already gone counts as removed
+			case status >= 300 && status != 404:
+				failed[lang] = fmt.Sprintf("Cloudflare returned HTTP %d", status)
+			default:
+				deleted = append(deleted, lang)
+			}
+		}(lang)
+	}
+
+	wg.Wait()
+	sort.Strings(deleted)
+	return deleted, failed
+}
+
+func registerCaptionRoutes(app *fiber.App, config CloudflareConfig, maxCaptions int, maxBytes int64, timeout fiber.Handler) {
+	// Upload a caption track as WebVTT, converting SubRip files first. Files may be
+	// up to maxBytes, checked on the request body before it is parsed. Adding a
+	// new language beyond maxCaptions (0 for no limit) is refused; replacing one
+	// is not.
+	app.Put("/api/video/:uid/captions/:lang", timeout, limitBody(int(maxBytes)+captionFormOverhead), func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+		lang := c.Params("lang")
+		if !languageTag.MatchString(lang) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Language must be a BCP 47 tag such as en or pt-BR",
+			})
+		}
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "No caption file provided",
+				"details": err.Error(),
+			})
+		}
+		if file.Size > maxBytes {
+			return c.Status(413).JSON(fiber.Map{
+				"error": "Caption file is too large",
+				"limit": maxBytes,
+			})
+		}
+
+		fileContent, err := file.Open()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not open file",
+				"details": err.Error(),
+			})
+		}
+		defer fileContent.Close()
+
+		// Check the header before anything is sent, so malformed files fail fast
+		reader := bufio.NewReader(fileContent)
+		header, err := reader.Peek(captionHeaderPeek)
+		if err != nil && err != io.EOF {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not read file",
+				"details": err.Error(),
+			})
+		}
+
+		format := "vtt"
+		var src io.Reader = reader
+		size := file.Size
+		if !isVTT(header) {
+			contents, err := io.ReadAll(reader)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Could not read file",
+					"details": err.Error(),
+				})
+			}
+			format = "srt"
+			contents, err = srtToVTT(contents)
+			if err != nil {
+				response := fiber.Map{
+					"error":   "Caption file is not valid VTT or SRT",
+					"details": err.Error(),
+				}
+				if parseErr, ok := err.(*CaptionParseError); ok {
+					response["line"] = parseErr.Line
+				}
+				return c.Status(400).JSON(response)
+			}
+			src, size = bytes.NewReader(contents), int64(len(contents))
+		}
+
+		if maxCaptions > 0 {
+			existing, err := listCaptions(c.UserContext(), config, uid)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Failed to list captions",
+					"details": err.Error(),
+				})
+			}
+			if !existing.Success {
+				return respondCloudflareError(c, "Failed to list captions", existing.Errors)
+			}
+
+			replacing := false
+			for _, caption := range existing.Result {
+				if strings.EqualFold(caption.Language, lang) {
+					replacing = true
+					break
+				}
+			}
+			if !replacing && len(existing.Result) >= maxCaptions {
+				return c.Status(409).JSON(fiber.Map{
+					"error": fmt.Sprintf("Video already has the maximum of %d caption languages", maxCaptions),
+					"count": len(existing.Result),
+					"max":   maxCaptions,
+				})
+			}
+		}
+
+		filename := lang + ".vtt"
+		body, contentType, writeDone := newMultipartUploadBody(src, filename)
+		defer body.Close()
+
+		req, err := newCloudflareRequest(c.UserContext(), config, "PUT", "/stream/"+uid+"/captions/"+lang, body)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not create request",
+				"details": err.Error(),
+			})
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.ContentLength = multipartContentLength(contentType, filename, size)
+
+		resp, err := httpClient.Do(req)
+		// Closing the pipe unblocks the writer so its goroutine always exits
+		body.Close()
+		writeErr := <-writeDone
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to upload captions",
+				"details": err.Error(),
+			})
+		}
+		defer resp.Body.Close()
+		if writeErr != nil && !errors.Is(writeErr, io.ErrClosedPipe) {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not copy file content",
+				"details": writeErr.Error(),
+			})
+		}
+
+		var result CaptionResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not parse response",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Caption upload failed", result.Errors)
+		}
+
+		return respond(c, 200, fiber.Map{
+			"language":       result.Result.Language,
+			"label":          result.Result.Label,
+			"uploadedFormat": format,
+		})
+	})
+
+	// Remove every caption track, e.g. before re-transcribing. A video without
+	// captions is left as it is.
+	app.Delete("/api/video/:uid/captions", timeout, func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		existing, err := listCaptions(c.UserContext(), config, uid)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to list captions",
+				"details": err.Error(),
+			})
+		}
+		if !existing.Success {
+			return respondCloudflareError(c, "Failed to list captions", existing.Errors)
+		}
+		if len(existing.Result) == 0 {
+			return c.SendStatus(204)
+		}
+
+		languages := make([]string, 0, len(existing.Result))
+		for _, caption := range existing.Result {
+			languages = append(languages, caption.Language)
+		}
+
+		deleted, failed := deleteCaptionsConcurrently(c.UserContext(), config, uid, languages)
+		if len(failed) > 0 {
+			return c.Status(502).JSON(fiber.Map{
+				"error":   "Some captions could not be deleted",
+				"deleted": deleted,
+				"failed":  failed,
+			})
+		}
+
+		return respond(c, 200, fiber.Map{
+			"deleted": deleted,
+		})
+	})
+
+	// Remove a single caption track
+	app.Delete("/api/video/:uid/captions/:lang", timeout, func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+		lang := c.Params("lang")
+		if !languageTag.MatchString(lang) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Language must be a BCP 47 tag such as en or pt-BR",
+			})
+		}
+
+		status, err := deleteResource(c.UserContext(), config, "/stream/"+uid+"/captions/"+lang)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to delete captions",
+				"details": err.Error(),
+			})
+		}
+		if status == 404 {
+			return c.Status(404).JSON(fiber.Map{
+				"error":    "No captions for this language",
+				"language": lang,
+			})
+		}
+		if status >= 300 {
+			return c.Status(502).JSON(fiber.Map{
+				"error":  "Cloudflare refused to delete the captions",
+				"status": status,
+			})
+		}
+
+		return c.SendStatus(204)
+	})
+
+	// Throw away a generated caption track and have Cloudflare generate it afresh.
+	// Refused while a generation for the language is still running, and for an
+	// uploaded track unless ?force=true, since that would discard the upload.
+	app.Post("/api/video/:uid/captions/:lang/regenerate", timeout, func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+		lang := c.Params("lang")
+		if !languageTag.MatchString(lang) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Language must be a BCP 47 tag such as en or pt-BR",
+			})
+		}
+
+		existing, err := listCaptions(c.UserContext(), config, uid)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to list captions",
+				"details": err.Error(),
+			})
+		}
+		if !existing.Success {
+			return respondCloudflareError(c, "Failed to list captions", existing.Errors)
+		}
+
+		for _, caption := range existing.Result {
+			if !strings.EqualFold(caption.Language, lang) {
+				continue
+			}
+			if caption.Status == captionGenerating {
+				return c.Status(409).JSON(fiber.Map{
+					"error":    "Captions for this language are already being generated",
+					"language": caption.Language,
+					"status":   caption.Status,
+				})
+			}
+			if !caption.Generated && !c.QueryBool("force", false) {
+				return c.Status(409).JSON(fiber.Map{
+					"error":    "Captions for this language were uploaded, pass force=true to replace them",
+					"language": caption.Language,
+				})
+			}
+
+			status, err := deleteResource(c.UserContext(), config, "/stream/"+uid+"/captions/"+caption.Language)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Failed to delete existing captions",
+					"details": err.Error(),
+				})
+			}
+			if status >= 300 && status != 404 {
+				return c.Status(502).JSON(fiber.Map{
+					"error":  "Cloudflare refused to delete the existing captions",
+					"status": status,
+				})
+			}
+			break
+		}
+
+		result, err := callCloudflare[CaptionResponse](c.UserContext(), config, "POST", "/stream/"+uid+"/captions/"+lang+"/generate", nil)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to generate captions",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Caption generation failed", result.Errors)
+		}
+
+		return respond(c, 202, fiber.Map{
+			"language":  result.Result.Language,
+			"label":     result.Result.Label,
+			"generated": result.Result.Generated,
+			"status":    result.Result.Status,
+		})
+	})
+
+	// Caption text for previewing in the UI without the player
+	app.Get("/api/video/:uid/captions/:lang/vtt", timeout, func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+		lang := c.Params("lang")
+		if !languageTag.MatchString(lang) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Language must be a BCP 47 tag such as en or pt-BR",
+			})
+		}
+
+		// The authenticated API serves captions for private videos too, so no token is needed
+		req, err := newCloudflareRequest(c.UserContext(), config, "GET", "/stream/"+uid+"/captions/"+lang+"/vtt", nil)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not create request",
+				"details": err.Error(),
+			})
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get captions",
+				"details": err.Error(),
+			})
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			return c.Status(404).JSON(fiber.Map{
+				"error":    "No captions for this language",
+				"language": lang,
+			})
+		}
+
+		contents, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not read captions",
+				"details": err.Error(),
+			})
+		}
+
+		// Failures come back as the usual JSON envelope rather than VTT
+		if resp.StatusCode != http.StatusOK {
+			var result CaptionResponse
+			if err := json.Unmarshal(contents, &result); err == nil && len(result.Errors) > 0 {
+				return respondCloudflareError(c, "Failed to get captions", result.Errors)
+			}
+			return c.Status(502).JSON(fiber.Map{
+				"error":  "Cloudflare returned an error for the captions",
+				"status": resp.StatusCode,
+			})
+		}
+
+		c.Set("Content-Type", "text/vtt; charset=utf-8")
+		return c.Send(contents)
+	})
+}
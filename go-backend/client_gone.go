@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// clientGoneInterval is how often a waiting handler checks its client is still connected
+const clientGoneInterval = 250 * time.Millisecond
+
+// watchClient calls cancel once the client closes its connection or the server
+// shuts down, whichever comes first, and stops watching when ctx ends.
+// fasthttp's Done channel only closes on shutdown, so the connection itself is
+// probed as well where the platform allows it.
+func watchClient(ctx context.Context, serverDone <-chan struct{}, conn net.Conn, cancel context.CancelFunc) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+
+	ticker := time.NewTicker(clientGoneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-serverDone:
+			cancel()
+			return
+		case <-ticker.C:
+			if connClosed(conn) {
+				cancel()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+//go:build linux || darwin || freebsd
+
+package main
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// connClosed peeks at the socket without consuming anything, so a pipelined
+// request is left for the server to read. A zero-byte read means the peer hung up.
+func connClosed(conn net.Conn) bool {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return false
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	closed := false
+	raw.Read(func(fd uintptr) bool {
+		var buf [1]byte
+		n, _, err := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		closed = (n == 0 && err == nil) || errors.Is(err, syscall.ECONNRESET)
+		// Never wait for the socket to become readable
+		return true
+	})
+	return closed
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClipRequest is the body accepted when clipping a video. Everything besides
+// the time range is optional and applies to the resulting clip.
+type ClipRequest struct {
+	StartTimeSeconds      int               `json:"startTimeSeconds" validate:"gte=0"`
+	EndTimeSeconds        int               `json:"endTimeSeconds" validate:"gtfield=StartTimeSeconds"`
+	Meta                  map[string]string `json:"meta" validate:"max=20,dive,keys,required,endkeys,max=1024"`
+	RequireSignedURLs     *bool             `json:"requireSignedURLs"`
+	AllowedOrigins        []string          `json:"allowedOrigins"`
+	ThumbnailTimestampPct *float64          `json:"thumbnailTimestampPct" validate:"omitempty,gte=0,lte=1"`
+	Creator               string            `json:"creator" validate:"max=64"`
+}
+
+// validateClipRequest checks the rules struct tags can't express: origin
+// hostnames and creator characters
+func validateClipRequest(body ClipRequest) error {
+	if _, err := normalizeOrigins(body.AllowedOrigins); err != nil {
+		return err
+	}
+
+	if body.Creator != "" {
+		if err := validateCreator(body.Creator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func registerClipRoutes(app *fiber.App, config CloudflareConfig, timeout fiber.Handler) {
+	// Create a new video from a time range of an existing one
+	app.Post("/api/video/:uid/clip", timeout, requireJSON(), func(c *fiber.Ctx) error {
+		var body ClipRequest
+		if ok, err := bindBody(c, &body); !ok {
+			return err
+		}
+
+		if err := validateClipRequest(body); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid clip request",
+				"details": err.Error(),
+			})
+		}
+
+		payload := fiber.Map{
+			"clippedFromVideoUID": c.Params("uid"),
+			"startTimeSeconds":    body.StartTimeSeconds,
+			"endTimeSeconds":      body.EndTimeSeconds,
+		}
+		if len(body.Meta) > 0 {
+			payload["meta"] = body.Meta
+		}
+		if body.RequireSignedURLs != nil {
+			payload["requireSignedURLs"] = *body.RequireSignedURLs
+		}
+		if body.AllowedOrigins != nil {
+			// Already validated above, so normalizing can't fail here
+			payload["allowedOrigins"], _ = normalizeOrigins(body.AllowedOrigins)
+		}
+		if body.ThumbnailTimestampPct != nil {
+			payload["thumbnailTimestampPct"] = *body.ThumbnailTimestampPct
+		}
+		if body.Creator != "" {
+			payload["creator"] = body.Creator
+		}
+
+		result, err := callCloudflare[VideoUploadResponse](c.UserContext(), config, "POST", "/stream/clip", payload)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to create clip",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Clip creation failed", result.Errors)
+		}
+
+		return respondResult(c, 201, newVideoDTO(result.Result), nil)
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// newCloudflareRequest builds an authenticated request against the configured account,
+// or the account selected for the request through its context.
+// The path is relative to the account, e.g. "/stream/<uid>".
+func newCloudflareRequest(ctx context.Context, config CloudflareConfig, method, path string, body io.Reader) (*http.Request, error) {
+	config = accountConfig(ctx, config)
+	url := fmt.Sprintf("%s/accounts/%s%s", config.BaseURL, config.AccountID, path)
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+config.APIToken)
+	return req, nil
+}
+
+// callCloudflare sends a request to an account-relative path and decodes Cloudflare's
+// response envelope into T. A non-nil payload is sent as a JSON body.
+func callCloudflare[T any](ctx context.Context, config CloudflareConfig, method, path string, payload interface{}) (*T, error) {
+	var body io.Reader
+	if payload != nil {
+		reqBody, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(reqBody)
+	}
+
+	req, err := newCloudflareRequest(ctx, config, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result T
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("could not parse response (HTTP %d): %w", resp.StatusCode, err)
+	}
+
+	return &result, nil
+}
+
+// fetchVideo retrieves the current details of a single video from Cloudflare
+func fetchVideo(ctx context.Context, config CloudflareConfig, uid string) (*VideoUploadResponse, error) {
+	return callCloudflare[VideoUploadResponse](ctx, config, "GET", "/stream/"+uid, nil)
+}
+
+// updateVideo edits a video's properties by posting a partial JSON body to Cloudflare
+func updateVideo(ctx context.Context, config CloudflareConfig, uid string, payload interface{}) (*VideoUploadResponse, error) {
+	return callCloudflare[VideoUploadResponse](ctx, config, "POST", "/stream/"+uid, payload)
+}
+
+// deleteResource issues a DELETE for an account-relative path and returns Cloudflare's
+// HTTP status, since delete endpoints answer with an empty body on success
+func deleteResource(ctx context.Context, config CloudflareConfig, path string) (int, error) {
+	req, err := newCloudflareRequest(ctx, config, "DELETE", path, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CloudflareError is a single entry in the errors array of a Cloudflare API response
+type CloudflareError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// defaultErrorStatus is returned for Cloudflare errors we don't recognise. Those are
+// treated as an upstream failure rather than something the caller did wrong.
+const defaultErrorStatus = 502
+
+// errorStatuses maps Cloudflare error codes to the HTTP status we answer with.
+// Codes describing a problem with the caller's input map to 4xx.
+var errorStatuses = map[int]int{
+	971:   429, // rate limited
+	10003: 404, // resource not found
+	10005: 400, // invalid request body or parameters
+	10006: 415, // unsupported or undecodable media
+	10011: 413, // file exceeds upload size limit
+}
+
+// loadErrorStatusOverrides applies a "code:status,code:status" list on top of the defaults
+func loadErrorStatusOverrides(spec string) error {
+	if spec == "" {
+		return nil
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		code, status, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return fmt.Errorf("invalid entry %q, expected code:status", entry)
+		}
+		c, err := strconv.Atoi(code)
+		if err != nil {
+			return fmt.Errorf("invalid error code %q", code)
+		}
+		s, err := strconv.Atoi(status)
+		if err != nil || s < 400 || s > 599 {
+			return fmt.Errorf("invalid HTTP status %q for code %d", status, c)
+		}
+		errorStatuses[c] = s
+	}
+
+	return nil
+}
+
+// cloudflareErrorStatus picks the HTTP status for a failed Cloudflare response
+// from the first error code we have a mapping for.
+func cloudflareErrorStatus(errs []CloudflareError) int {
+	for _, e := range errs {
+		if status, ok := errorStatuses[e.Code]; ok {
+			return status
+		}
+	}
+	return defaultErrorStatus
+}
+
+// respondCloudflareError writes the standard error body for an unsuccessful Cloudflare response
+func respondCloudflareError(c *fiber.Ctx, message string, errs []CloudflareError) error {
+	status := cloudflareErrorStatus(errs)
+	if status == 429 {
+		return respondRateLimited(c, message, errs)
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error":   message,
+		"details": errs,
+	})
+}
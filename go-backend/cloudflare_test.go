@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCallCloudflareSuccess(t *testing.T) {
+	config := newFakeCloudflare(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/accounts/acc/stream/abc" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q", got)
+		}
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload["uid"] != "abc" {
+			t.Errorf("payload = %v (%v)", payload, err)
+		}
+		io.WriteString(w, `{"success":true,"errors":[],"messages":[],"result":{"uid":"abc","readyToStream":true}}`)
+	}))
+
+	result, err := callCloudflare[VideoUploadResponse](context.Background(), config, "POST", "/stream/abc", map[string]string{"uid": "abc"})
+	if err != nil {
+		t.Fatalf("callCloudflare: %v", err)
+	}
+	if !result.Success || result.Result.UID != "abc" || !result.Result.ReadyToStream {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestCallCloudflareNonJSONError(t *testing.T) {
+	config := newFakeCloudflare(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+		io.WriteString(w, "<html>Service Unavailable</html>")
+	}))
+
+	result, err := callCloudflare[VideoUploadResponse](context.Background(), config, "GET", "/stream/abc", nil)
+	if err == nil {
+		t.Fatalf("expected an error, got %+v", result)
+	}
+	if !strings.Contains(err.Error(), "HTTP 503") {
+		t.Errorf("err = %v, want it to name the HTTP status", err)
+	}
+}
+
+func TestCallCloudflareTransportError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	config := CloudflareConfig{AccountID: "acc", APIToken: "token", BaseURL: server.URL}
+	// Nothing listens once the server is closed, so the request fails to connect
+	server.Close()
+
+	result, err := callCloudflare[VideoUploadResponse](context.Background(), config, "GET", "/stream/abc", nil)
+	if err == nil {
+		t.Fatalf("expected a transport error, got %+v", result)
+	}
+	if result != nil {
+		t.Errorf("result = %+v, want nil on a transport error", result)
+	}
+}
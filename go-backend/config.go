@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envDuration reads a Go duration (e.g. "30m") from the environment, falling back to def
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		fmt.Printf("Invalid %s %q, using default %s\n", key, value, def)
+		return def
+	}
+	return d
+}
+
+// envInt reads an integer from the environment, falling back to def
+func envInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		fmt.Printf("Invalid %s %q, using default %d\n", key, value, def)
+		return def
+	}
+	return n
+}
+
+// envFloat reads a decimal number from the environment, falling back to def
+func envFloat(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		fmt.Printf("Invalid %s %q, using default %g\n", key, value, def)
+		return def
+	}
+	return f
+}
+
+// envBool reads a boolean ("true", "1", ...) from the environment, falling back to def
+func envBool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		fmt.Printf("Invalid %s %q, using default %t\n", key, value, def)
+		return def
+	}
+	return b
+}
+
+// envSet reads a comma-separated list from the environment as a set
+func envSet(key string) map[string]bool {
+	set := make(map[string]bool)
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			set[item] = true
+		}
+	}
+	return set
+}
+
+// defaultCloudflareBaseURL is Cloudflare's production API
+const defaultCloudflareBaseURL = "https://api.cloudflare.com/client/v4"
+
+// resolveBaseURL picks the Cloudflare API base URL. CLOUDFLARE_BASE_URL wins when
+// set; otherwise ENVIRONMENT (default "production") selects CLOUDFLARE_BASE_URL_<ENV>,
+// with production falling back to Cloudflare's public API.
+func resolveBaseURL() (string, error) {
+	environment := strings.ToUpper(strings.TrimSpace(os.Getenv("ENVIRONMENT")))
+	if environment == "" {
+		environment = "PRODUCTION"
+	}
+
+	source := "CLOUDFLARE_BASE_URL"
+	baseURL := os.Getenv(source)
+	if baseURL == "" {
+		source = "CLOUDFLARE_BASE_URL_" + environment
+		baseURL = os.Getenv(source)
+	}
+	if baseURL == "" {
+		if environment != "PRODUCTION" {
+			return "", fmt.Errorf("ENVIRONMENT is %s but %s is not set", environment, source)
+		}
+		return defaultCloudflareBaseURL, nil
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", fmt.Errorf("%s %q is not an absolute http(s) URL", source, baseURL)
+	}
+	return strings.TrimSuffix(baseURL, "/"), nil
+}
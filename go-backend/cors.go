@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+)
+
+// defaultCORSOrigins is the Vite dev server, the only origin allowed when nothing is configured
+const defaultCORSOrigins = "http://localhost:5173"
+
+// playbackRouteSuffixes are the per-video GET routes serving media or embeds,
+// which may be loaded from third-party sites
+var playbackRouteSuffixes = []string{
+	"/thumbnail",
+	"/thumbnail-url",
+	"/manifest/video.m3u8",
+	"/hls",
+	"/storyboard",
+	"/poster",
+	"/mp4",
+	"/player",
+	"/public-details",
+	"/renditions",
+	"/vtt",
+}
+
+// CORSOrigins holds the allowed origins for each route group, as comma-separated lists
+type CORSOrigins struct {
+	Default  string
+	Upload   string
+	Playback string
+}
+
+// loadCORSOrigins reads CORS_ORIGINS for the API as a whole, and
+// CORS_UPLOAD_ORIGINS and CORS_PLAYBACK_ORIGINS for those groups, which fall
+// back to CORS_ORIGINS when unset
+func loadCORSOrigins() CORSOrigins {
+	origins := CORSOrigins{Default: os.Getenv("CORS_ORIGINS")}
+	if origins.Default == "" {
+		origins.Default = defaultCORSOrigins
+	}
+	origins.Upload = os.Getenv("CORS_UPLOAD_ORIGINS")
+	if origins.Upload == "" {
+		origins.Upload = origins.Default
+	}
+	origins.Playback = os.Getenv("CORS_PLAYBACK_ORIGINS")
+	if origins.Playback == "" {
+		origins.Playback = origins.Default
+	}
+	return origins
+}
+
+// isUploadRoute reports whether path creates videos from files or upload URLs
+func isUploadRoute(path string) bool {
+	return path == "/api/upload" || path == "/api/direct-upload"
+}
+
+// isPlaybackRoute reports whether a request reads media or embeds. Only reads
+// count, so e.g. replacing a poster keeps the default policy.
+func isPlaybackRoute(method, path string) bool {
+	if method != fiber.MethodGet && method != fiber.MethodHead {
+		return false
+	}
+	if strings.HasPrefix(path, "/v/") {
+		return true
+	}
+	if !strings.HasPrefix(path, "/api/video/") {
+		return false
+	}
+	for _, suffix := range playbackRouteSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsByRouteGroup applies the upload or playback CORS policy to those routes and
+// the default policy to the rest. Preflights are matched on the method they ask about.
+func corsByRouteGroup(origins CORSOrigins) fiber.Handler {
+	policy := func(allowOrigins string) fiber.Handler {
+		return cors.New(cors.Config{
+			AllowOrigins: allowOrigins,
+			AllowHeaders: "Origin, Content-Type, Accept, Authorization, X-API-Key",
+			AllowMethods: "GET, POST, PUT, DELETE",
+		})
+	}
+	defaultPolicy := policy(origins.Default)
+	uploadPolicy := policy(origins.Upload)
+	playbackPolicy := policy(origins.Playback)
+
+	return func(c *fiber.Ctx) error {
+		method := c.Method()
+		if method == fiber.MethodOptions {
+			method = c.Get(fiber.HeaderAccessControlRequestMethod)
+		}
+
+		switch path := c.Path(); {
+		case isUploadRoute(path):
+			return uploadPolicy(c)
+		case isPlaybackRoute(method, path):
+			return playbackPolicy(c)
+		default:
+			return defaultPolicy(c)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxCreatorLength is the longest creator identifier Cloudflare accepts
+const maxCreatorLength = 64
+
+// creatorPattern restricts creator IDs to characters that are safe in URLs and logs
+var creatorPattern = regexp.MustCompile(`^[A-Za-z0-9._@:-]+$`)
+
+// CreatorRequest is the body accepted when setting a video's creator
+type CreatorRequest struct {
+	Creator string `json:"creator" validate:"required,max=64"`
+}
+
+// validateCreator checks a creator identifier's length and characters
+func validateCreator(creator string) error {
+	if creator == "" {
+		return errors.New("creator must not be empty")
+	}
+	if len(creator) > maxCreatorLength {
+		return errors.New("creator must be at most 64 characters")
+	}
+	if !creatorPattern.MatchString(creator) {
+		return errors.New("creator may only contain letters, digits and . _ @ : -")
+	}
+	return nil
+}
+
+func registerCreatorRoutes(app *fiber.App, config CloudflareConfig, timeout fiber.Handler) {
+	// The end-user a video is attributed to
+	app.Get("/api/video/:uid/creator", timeout, func(c *fiber.Ctx) error {
+		result, err := fetchVideo(c.UserContext(), config, c.Params("uid"))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Failed to get video", result.Errors)
+		}
+
+		return respond(c, 200, fiber.Map{
+			"creator": result.Result.Creator,
+		})
+	})
+
+	app.Post("/api/video/:uid/creator", timeout, requireJSON(), func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		var body CreatorRequest
+		if ok, err := bindBody(c, &body); !ok {
+			return err
+		}
+
+		if err := validateCreator(body.Creator); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid creator",
+				"details": err.Error(),
+			})
+		}
+
+		result, err := updateVideo(c.UserContext(), config, uid, fiber.Map{
+			"uid":     uid,
+			"creator": body.Creator,
+		})
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to update video",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Failed to update creator", result.Errors)
+		}
+
+		return respond(c, 200, fiber.Map{
+			"creator": result.Result.Creator,
+		})
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// ContentIndex maps uploaded content, keyed by account and SHA-256 (see
+// contentIndexKey), to the UID Cloudflare assigned it
+type ContentIndex interface {
+	Lookup(key string) (uid string, ok bool)
+	Store(key, uid string)
+	Remove(key string)
+}
+
+// memoryContentIndex is the default ContentIndex; it is lost on restart
+type memoryContentIndex struct {
+	mu     sync.RWMutex
+	hashes map[string]string
+}
+
+func newMemoryContentIndex() *memoryContentIndex {
+	return &memoryContentIndex{hashes: make(map[string]string)}
+}
+
+func (m *memoryContentIndex) Lookup(hash string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	uid, ok := m.hashes[hash]
+	return uid, ok
+}
+
+func (m *memoryContentIndex) Store(hash, uid string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hashes[hash] = uid
+}
+
+func (m *memoryContentIndex) Remove(hash string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.hashes, hash)
+}
+
+// contentIndexKey scopes a content hash to an account, so one account's upload
+// never resolves to another account's video
+func contentIndexKey(accountID, hash string) string {
+	return accountID + "/" + hash
+}
+
+// uploadOptionsMatch reports whether an existing video already has what an
+// upload asked for: the same signing choice and priority, and the same labels
+// when any were given
+func uploadOptionsMatch(video *VideoUploadResponse, opts UploadOptions) bool {
+	if video.Result.RequireSignedURLs != opts.RequireSigned {
+		return false
+	}
+	if priority, _ := videoMeta(video)["priority"].(string); priority != opts.Priority {
+		return false
+	}
+	return len(opts.Labels) == 0 || video.Result.Meta.Labels == joinLabels(opts.Labels)
+}
+
+// lookupDuplicate returns the video stored under key when it still exists and
+// hasn't failed processing. Entries for videos that are gone, unreachable or
+// errored are removed, so the upload is kept as new content.
+func lookupDuplicate(ctx context.Context, config CloudflareConfig, index ContentIndex, key string) (*VideoUploadResponse, bool) {
+	uid, ok := index.Lookup(key)
+	if !ok {
+		return nil, false
+	}
+	existing, err := fetchVideo(ctx, config, uid)
+	if err != nil || !existing.Success || existing.Result.Status.State == "error" {
+		index.Remove(key)
+		return nil, false
+	}
+	return existing, true
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLookupDuplicate(t *testing.T) {
+	config := newFakeCloudflare(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uid := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		switch uid {
+		case "ready", "error":
+			fmt.Fprintf(w, `{"success":true,"errors":[],"messages":[],"result":{"uid":%q,"status":{"state":%q}}}`, uid, uid)
+		default:
+			w.WriteHeader(404)
+			fmt.Fprint(w, `{"success":false,"errors":[{"code":10005,"message":"not found"}],"messages":[],"result":null}`)
+		}
+	}))
+
+	for _, tc := range []struct {
+		uid  string
+		want bool
+	}{
+		{"ready", true},
+		{"error", false},
+		{"gone", false},
+	} {
+		index := newMemoryContentIndex()
+		index.Store("acc/hash", tc.uid)
+
+		_, ok := lookupDuplicate(context.Background(), config, index, "acc/hash")
+		if ok != tc.want {
+			t.Errorf("%s video: duplicate = %v, want %v", tc.uid, ok, tc.want)
+		}
+		if _, kept := index.Lookup("acc/hash"); kept != tc.want {
+			t.Errorf("%s video: index entry kept = %v, want %v", tc.uid, kept, tc.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Cloudflare only accepts direct upload expiries between 2 minutes and 6 hours from now
+const (
+	directUploadMinExpiry = 2 * time.Minute
+	directUploadMaxExpiry = 6 * time.Hour
+)
+
+// DirectUploadRequest is the body accepted by the direct upload endpoint
+type DirectUploadRequest struct {
+	MaxDurationSeconds int    `json:"maxDurationSeconds"`
+	Expiry             string `json:"expiry"`
+	Name               string `json:"name"`
+}
+
+// DirectUploadResponse represents Cloudflare's response to a direct_upload request
+type DirectUploadResponse struct {
+	Result struct {
+		UploadURL string `json:"uploadURL"`
+		UID       string `json:"uid"`
+	} `json:"result"`
+	Success  bool              `json:"success"`
+	Errors   []CloudflareError `json:"errors"`
+	Messages []string          `json:"messages"`
+}
+
+// DirectUploadConfig holds the defaults applied to direct creator uploads
+type DirectUploadConfig struct {
+	TTL                time.Duration
+	MaxDurationSeconds int
+}
+
+// resolveDirectUploadExpiry parses a requested expiry (or applies the default TTL)
+// and checks it falls within Cloudflare's allowed window.
+func resolveDirectUploadExpiry(requested string, ttl time.Duration, now time.Time) (time.Time, error) {
+	expiry := now.Add(ttl)
+	if requested != "" {
+		parsed, err := time.Parse(time.RFC3339, requested)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("expiry must be an RFC3339 timestamp, e.g. %s", now.Add(time.Hour).UTC().Format(time.RFC3339))
+		}
+		expiry = parsed
+	}
+
+	if until := expiry.Sub(now); until < directUploadMinExpiry || until > directUploadMaxExpiry {
+		return time.Time{}, fmt.Errorf("expiry must be between %s and %s from now", directUploadMinExpiry, directUploadMaxExpiry)
+	}
+
+	return expiry, nil
+}
+
+func registerDirectUploadRoutes(app *fiber.App, config CloudflareConfig, uploadConfig DirectUploadConfig, store VideoStore, timeout fiber.Handler) {
+	if _, err := resolveDirectUploadExpiry("", uploadConfig.TTL, time.Now()); err != nil {
+		fmt.Printf("Warning: DIRECT_UPLOAD_TTL %s is outside Cloudflare's window: %v\n", uploadConfig.TTL, err)
+	}
+
+	// Create a one-time upload URL the browser can upload to directly
+	app.Post("/api/direct-upload", timeout, requireJSON(), func(c *fiber.Ctx) error {
+		var body DirectUploadRequest
+		if len(c.Body()) > 0 {
+			if ok, err := bindBody(c, &body); !ok {
+				return err
+			}
+		}
+
+		expiry, err := resolveDirectUploadExpiry(body.Expiry, uploadConfig.TTL, time.Now())
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid expiry",
+				"details": err.Error(),
+			})
+		}
+
+		maxDuration := body.MaxDurationSeconds
+		if maxDuration == 0 {
+			maxDuration = uploadConfig.MaxDurationSeconds
+		}
+		if maxDuration < 1 {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "maxDurationSeconds must be positive",
+			})
+		}
+
+		payload := fiber.Map{
+			"maxDurationSeconds": maxDuration,
+			"expiry":             expiry.UTC().Format(time.RFC3339),
+		}
+		if body.Name != "" {
+			payload["meta"] = fiber.Map{"name": body.Name}
+		}
+
+		result, err := callCloudflare[DirectUploadResponse](c.UserContext(), config, "POST", "/stream/direct_upload", payload)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to create direct upload",
+				"details": err.Error(),
+			})
+		}
+
+		if !result.Success {
+			return respondCloudflareError(c, "Direct upload failed", result.Errors)
+		}
+
+		// Remember the upload so it can be followed until the browser finishes it
+		trackPendingUpload(store, accountConfig(c.UserContext(), config).AccountID, result.Result.UID, expiry)
+
+		return respond(c, 200, fiber.Map{
+			"uid":       result.Result.UID,
+			"uploadURL": result.Result.UploadURL,
+			"expiry":    expiry.UTC().Format(time.RFC3339),
+		})
+	})
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// DTO field naming styles, chosen globally with JSON_FIELD_CASE
+const (
+	fieldCaseCamel = "camel"
+	fieldCaseSnake = "snake"
+)
+
+// jsonFieldCase is the casing VideoDTO fields are serialized with
+var jsonFieldCase = fieldCaseCamel
+
+// loadJSONFieldCase reads JSON_FIELD_CASE, defaulting to camelCase
+func loadJSONFieldCase() (string, error) {
+	switch fieldCase := os.Getenv("JSON_FIELD_CASE"); fieldCase {
+	case "":
+		return fieldCaseCamel, nil
+	case fieldCaseCamel, fieldCaseSnake:
+		return fieldCase, nil
+	default:
+		return "", fmt.Errorf("JSON_FIELD_CASE must be %s or %s, got %q", fieldCaseCamel, fieldCaseSnake, fieldCase)
+	}
+}
+
+// snakeCase converts a camelCase name to snake_case. Runs of capitals are kept
+// together, so "requireSignedURLs" becomes "require_signed_urls".
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// dtoPassThroughKeys name DTO fields holding user data rather than more DTO
+// fields. Their contents keep the keys the user gave them whatever the field case.
+var dtoPassThroughKeys = map[string]bool{"meta": true}
+
+// snakeCaseKeys rewrites the DTO's own object keys in a decoded JSON value to
+// snake_case, leaving the contents of dtoPassThroughKeys as they are
+func snakeCaseKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if dtoPassThroughKeys[key] {
+				out[key] = item
+				continue
+			}
+			out[snakeCase(key)] = snakeCaseKeys(item)
+		}
+		return out
+	case []interface{}:
+		for i, item := range v {
+			v[i] = snakeCaseKeys(item)
+		}
+		return v
+	}
+	return value
+}
+
+// errorReasonMessages translates Cloudflare's errorReasonCode values into
+// messages that can be shown to end-users as-is
+var errorReasonMessages = map[string]string{
+	"ERR_NON_VIDEO":          "The uploaded file is not a video.",
+	"ERR_MALFORMED_VIDEO":    "The video file is corrupted or uses an unsupported encoding.",
+	"ERR_FETCH_ORIGIN_ERROR": "The video could not be downloaded from its source URL.",
+	"ERR_DURATION_TOO_SHORT": "The video is too short to be processed.",
+	"ERR_UNKNOWN":            "Processing failed for an unknown reason. Please try uploading again.",
+}
+
+// Reasons a video is or isn't ready to stream, reported as readyReason
+const (
+	readyReasonReady          = "ready"
+	readyReasonAwaitingUpload = "awaitingUpload"
+	readyReasonProcessing     = "processing"
+	readyReasonLive           = "live"
+	readyReasonFailed         = "failed"
+	readyReasonUnknown        = "unknown"
+)
+
+// stateReadyReasons maps Cloudflare's status.state values to ready reasons
+var stateReadyReasons = map[string]string{
+	"pendingupload":   readyReasonAwaitingUpload,
+	"downloading":     readyReasonProcessing,
+	"queued":          readyReasonProcessing,
+	"inprogress":      readyReasonProcessing,
+	"live-inprogress": readyReasonLive,
+	"ready":           readyReasonReady,
+	"error":           readyReasonFailed,
+}
+
+// readyReason explains readyToStream. An error code means failure whatever the
+// state says, and readyToStream wins over a state still reporting more renditions.
+func readyReason(result CloudflareResult) string {
+	switch {
+	case result.Status.ErrorReasonCode != "":
+		return readyReasonFailed
+	case result.ReadyToStream:
+		return readyReasonReady
+	}
+	if reason, ok := stateReadyReasons[result.Status.State]; ok && reason != readyReasonReady {
+		return reason
+	}
+	return readyReasonUnknown
+}
+
+// playbackPreferences are the accepted values of the status endpoint's playback param
+var playbackPreferences = map[string]bool{"hls": true, "dash": true, "both": true}
+
+// VideoDTO is the video shape returned to clients: Cloudflare's result plus derived fields
+type VideoDTO struct {
+	CloudflareResult
+	ErrorMessage string        `json:"errorMessage,omitempty"`
+	URLs         *PlaybackURLs `json:"urls,omitempty"`
+	Labels       []string      `json:"labels,omitempty"`
+	// SizePending is set while Cloudflare hasn't reported the file size yet, as
+	// during encoding; size reads 0 until then
+	SizePending bool `json:"sizePending"`
+	// ReadyReason says why readyToStream is what it is: ready, awaitingUpload,
+	// processing, live, failed or unknown
+	ReadyReason string `json:"readyReason"`
+}
+
+// videoDTOFields has VideoDTO's fields without its MarshalJSON method
+type videoDTOFields VideoDTO
+
+// MarshalJSON writes the DTO with its tagged camelCase names, or converts them
+// to snake_case when JSON_FIELD_CASE=snake
+func (d VideoDTO) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(videoDTOFields(d))
+	if err != nil || jsonFieldCase != fieldCaseSnake {
+		return data, err
+	}
+
+	var fields interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// Keep numbers as written so sizes and durations survive the round trip exactly
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(snakeCaseKeys(fields))
+}
+
+// friendlyErrorMessage describes why processing failed, or "" if it hasn't
+func friendlyErrorMessage(result CloudflareResult) string {
+	code := result.Status.ErrorReasonCode
+	if code == "" {
+		return ""
+	}
+
+	// Uploads over maxDurationSeconds are accepted and then fail during processing
+	if code == "ERR_DURATION_EXCEED_CONSTRAINT" {
+		if result.MaxDurationSeconds > 0 {
+			return fmt.Sprintf("The video exceeds the %d-second limit.", result.MaxDurationSeconds)
+		}
+		return "The video exceeds the maximum allowed duration."
+	}
+
+	if message, ok := errorReasonMessages[code]; ok {
+		return message
+	}
+	if result.Status.ErrorReasonText != "" {
+		return result.Status.ErrorReasonText
+	}
+	return errorReasonMessages["ERR_UNKNOWN"]
+}
+
+func newVideoDTO(result CloudflareResult) VideoDTO {
+	return VideoDTO{
+		CloudflareResult: result,
+		ErrorMessage:     friendlyErrorMessage(result),
+		Labels:           splitLabels(result.Meta.Labels),
+		SizePending:      result.Size == 0,
+		ReadyReason:      readyReason(result),
+	}
+}
+
+// applyPlaybackPreference drops the playback URLs for the protocol the client didn't ask for
+func applyPlaybackPreference(dto *VideoDTO, preference string) {
+	switch preference {
+	case "hls":
+		dto.Playback.Dash = ""
+		if dto.URLs != nil {
+			dto.URLs.Dash = ""
+		}
+	case "dash":
+		dto.Playback.HLS = ""
+		if dto.URLs != nil {
+			dto.URLs.HLS = ""
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSnakeCaseKeysLeavesMetaAlone(t *testing.T) {
+	got := snakeCaseKeys(map[string]interface{}{
+		"readyToStream": true,
+		"status":        map[string]interface{}{"errorReasonCode": ""},
+		"meta":          map[string]interface{}{"name": "clip", "customerId": "c-1", "nestedData": map[string]interface{}{"keepMe": 1}},
+		"labels":        []interface{}{"camelLabel"},
+	})
+	want := map[string]interface{}{
+		"ready_to_stream": true,
+		"status":          map[string]interface{}{"error_reason_code": ""},
+		"meta":            map[string]interface{}{"name": "clip", "customerId": "c-1", "nestedData": map[string]interface{}{"keepMe": 1}},
+		"labels":          []interface{}{"camelLabel"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("snakeCaseKeys = %v, want %v", got, want)
+	}
+}
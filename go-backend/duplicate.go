@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DownloadsResponse represents Cloudflare's MP4 download state for a video
+type DownloadsResponse struct {
+	Result struct {
+		Default *struct {
+			Status          string  `json:"status"`
+			URL             string  `json:"url"`
+			PercentComplete float64 `json:"percentComplete"`
+		} `json:"default"`
+	} `json:"result"`
+	Success  bool              `json:"success"`
+	Errors   []CloudflareError `json:"errors"`
+	Messages []string          `json:"messages"`
+}
+
+// DuplicateRequest is the optional body accepted when duplicating a video
+type DuplicateRequest struct {
+	RequireSignedURLs *bool `json:"requireSignedURLs"`
+}
+
+func registerDuplicateRoutes(app *fiber.App, config CloudflareConfig, requireSignedDefault bool, timeout fiber.Handler) {
+	// Copy a video into a new one via its MP4 download, keeping its settings.
+	// The copy stays private if the source was, unless the request says otherwise.
+	app.Post("/api/video/:uid/duplicate", timeout, requireJSON(), func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		var body DuplicateRequest
+		if len(c.Body()) > 0 {
+			if ok, err := bindBody(c, &body); !ok {
+				return err
+			}
+		}
+
+		source, err := fetchVideo(c.UserContext(), config, uid)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !source.Success {
+			return respondCloudflareError(c, "Failed to get video", source.Errors)
+		}
+		if !source.Result.ReadyToStream {
+			return c.Status(409).JSON(fiber.Map{
+				"error": "Video is not ready yet and cannot be duplicated",
+				"state": source.Result.Status.State,
+			})
+		}
+
+		// Cloudflare copies from a URL, so the source needs an MP4 download
+		downloads, err := callCloudflare[DownloadsResponse](c.UserContext(), config, "GET", "/stream/"+uid+"/downloads", nil)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get downloads",
+				"details": err.Error(),
+			})
+		}
+		if !downloads.Success || downloads.Result.Default == nil {
+			downloads, err = callCloudflare[DownloadsResponse](c.UserContext(), config, "POST", "/stream/"+uid+"/downloads", fiber.Map{})
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Failed to enable downloads",
+					"details": err.Error(),
+				})
+			}
+			if !downloads.Success || downloads.Result.Default == nil {
+				return respondCloudflareError(c, "Downloads could not be enabled on the source video", downloads.Errors)
+			}
+		}
+
+		download := downloads.Result.Default
+		if download.Status != "ready" {
+			return c.Status(409).JSON(fiber.Map{
+				"error":           "Source download is still being generated, retry shortly",
+				"status":          download.Status,
+				"percentComplete": download.PercentComplete,
+			})
+		}
+
+		// Private videos need a signed URL for Cloudflare to fetch the download
+		downloadURL := download.URL
+		if source.Result.RequireSignedURLs {
+			id, err := playbackID(c.UserContext(), config, source.Result)
+			if err != nil {
+				return respondSigningError(c, 500, "Could not sign download URL", err)
+			}
+			downloadURL = strings.Replace(downloadURL, "/"+uid+"/", "/"+id+"/", 1)
+		}
+
+		payload := fiber.Map{
+			"url":               downloadURL,
+			"meta":              videoMeta(source),
+			"requireSignedURLs": resolveRequireSignedURLs(body.RequireSignedURLs, requireSignedDefault || source.Result.RequireSignedURLs),
+		}
+		if len(source.Result.AllowedOrigins) > 0 {
+			payload["allowedOrigins"] = source.Result.AllowedOrigins
+		}
+		if source.Result.Creator != "" {
+			payload["creator"] = source.Result.Creator
+		}
+
+		copied, err := callCloudflare[VideoUploadResponse](c.UserContext(), config, "POST", "/stream/copy", payload)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to copy video",
+				"details": err.Error(),
+			})
+		}
+		if !copied.Success {
+			return respondCloudflareError(c, "Video copy failed", copied.Errors)
+		}
+
+		return respond(c, 201, fiber.Map{
+			"uid":    copied.Result.UID,
+			"source": uid,
+		})
+	})
+}
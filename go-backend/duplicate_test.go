@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// fakeCopyCloudflare serves a ready public video with a ready MP4 download and
+// records the body of the copy request
+type fakeCopyCloudflare struct {
+	mu   sync.Mutex
+	copy map[string]interface{}
+}
+
+func (f *fakeCopyCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var result interface{}
+	switch r.URL.Path {
+	case "/accounts/acc/stream/abc":
+		result = map[string]interface{}{
+			"uid":           "abc",
+			"readyToStream": true,
+			"meta":          map[string]interface{}{"name": "clip"},
+		}
+	case "/accounts/acc/stream/abc/downloads":
+		result = map[string]interface{}{
+			"default": map[string]interface{}{"status": "ready", "url": "https://customer-x.cloudflarestream.com/abc/downloads/default.mp4"},
+		}
+	case "/accounts/acc/stream/copy":
+		f.mu.Lock()
+		json.NewDecoder(r.Body).Decode(&f.copy)
+		f.mu.Unlock()
+		result = map[string]interface{}{"uid": "copy1"}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"errors":   []interface{}{},
+		"messages": []interface{}{},
+		"result":   result,
+	})
+}
+
+func duplicateVideo(t *testing.T, requireSignedDefault bool, body string) map[string]interface{} {
+	t.Helper()
+	fake := &fakeCopyCloudflare{}
+	config := newFakeCloudflare(t, fake)
+
+	app := fiber.New()
+	registerDuplicateRoutes(app, config, requireSignedDefault, noTimeout)
+
+	req := httptest.NewRequest("POST", "/api/video/abc/duplicate", strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 201 {
+		data, _ := io.ReadAll(resp.Body)
+		t.Fatalf("status = %d, body %s", resp.StatusCode, data)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.copy == nil {
+		t.Fatal("no copy request reached Cloudflare")
+	}
+	return fake.copy
+}
+
+func TestDuplicateIsPrivateUnderDefault(t *testing.T) {
+	copied := duplicateVideo(t, true, "")
+	if copied["requireSignedURLs"] != true {
+		t.Errorf("copy of a public video sent requireSignedURLs = %v under a private default", copied["requireSignedURLs"])
+	}
+}
+
+func TestDuplicateRequestOverridesDefault(t *testing.T) {
+	copied := duplicateVideo(t, true, `{"requireSignedURLs":false}`)
+	if copied["requireSignedURLs"] != false {
+		t.Errorf("requireSignedURLs = %v, want the request's false", copied["requireSignedURLs"])
+	}
+}
+
+func TestDuplicateOfPublicVideoStaysPublic(t *testing.T) {
+	copied := duplicateVideo(t, false, "")
+	if copied["requireSignedURLs"] != false {
+		t.Errorf("requireSignedURLs = %v, want false without a private default", copied["requireSignedURLs"])
+	}
+}
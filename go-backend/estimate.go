@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// EstimateRates are the per-minute prices used for cost estimates. Stream bills
+// storage per minute stored each month and delivery per minute watched.
+type EstimateRates struct {
+	StoragePerMinute  float64 `json:"storagePerMinute"`
+	DeliveryPerMinute float64 `json:"deliveryPerMinute"`
+}
+
+// CostEstimate breaks an estimate down by what Stream bills for
+type CostEstimate struct {
+	Storage  float64 `json:"storage"`
+	Delivery float64 `json:"delivery"`
+	Total    float64 `json:"total"`
+}
+
+// roundCost rounds to a hundredth of a cent so tiny videos don't show as free
+func roundCost(cost float64) float64 {
+	return math.Round(cost*10000) / 10000
+}
+
+// estimateCost prices storing a video of the given length for some months and
+// having it watched in full the given number of times
+func estimateCost(durationSeconds float64, months, views int, rates EstimateRates) CostEstimate {
+	minutes := durationSeconds / 60
+	storage := minutes * float64(months) * rates.StoragePerMinute
+	delivery := minutes * float64(views) * rates.DeliveryPerMinute
+	return CostEstimate{
+		Storage:  roundCost(storage),
+		Delivery: roundCost(delivery),
+		Total:    roundCost(storage + delivery),
+	}
+}
+
+func registerEstimateRoutes(app *fiber.App, config CloudflareConfig, rates EstimateRates, timeout fiber.Handler) {
+	// Estimate what a video costs to store for ?months= and deliver ?views= times
+	app.Get("/api/video/:uid/estimate", timeout, func(c *fiber.Ctx) error {
+		months := c.QueryInt("months", 1)
+		views := c.QueryInt("views", 0)
+		if months < 0 || views < 0 {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "months and views must not be negative",
+			})
+		}
+
+		video, err := fetchVideo(c.UserContext(), config, c.Params("uid"))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !video.Success {
+			return respondCloudflareError(c, "Failed to get video", video.Errors)
+		}
+
+		// Cloudflare reports -1 until the video has been processed
+		duration := video.Result.Duration
+		if duration < 0 {
+			return c.Status(409).JSON(fiber.Map{
+				"error": "Video duration is not known until processing finishes",
+				"state": video.Result.Status.State,
+			})
+		}
+
+		return respond(c, 200, fiber.Map{
+			"uid":             video.Result.UID,
+			"durationSeconds": duration,
+			"durationMinutes": math.Round(duration/60*100) / 100,
+			"months":          months,
+			"views":           views,
+			"rates":           rates,
+			"estimate":        estimateCost(duration, months, views, rates),
+		})
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// sseKeepAliveInterval controls how often idle SSE connections receive a comment line
+const sseKeepAliveInterval = 15 * time.Second
+
+// VideoHub is an in-process pub/sub registry of UID -> subscriber channels
+type VideoHub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan CloudflareResult]struct{}
+}
+
+func newVideoHub() *VideoHub {
+	return &VideoHub{
+		subscribers: make(map[string]map[chan CloudflareResult]struct{}),
+	}
+}
+
+// Subscribe registers interest in updates for a UID. The returned function
+// must be called to release the subscription.
+func (h *VideoHub) Subscribe(uid string) (<-chan CloudflareResult, func()) {
+	ch := make(chan CloudflareResult, 4)
+
+	h.mu.Lock()
+	if h.subscribers[uid] == nil {
+		h.subscribers[uid] = make(map[chan CloudflareResult]struct{})
+	}
+	h.subscribers[uid][ch] = struct{}{}
+	h.mu.Unlock()
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if subs, ok := h.subscribers[uid]; ok {
+			delete(subs, ch)
+			if len(subs) == 0 {
+				delete(h.subscribers, uid)
+			}
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish delivers an update to every subscriber watching the UID.
+// Slow subscribers whose buffer is full are skipped rather than blocking the publisher.
+func (h *VideoHub) Publish(uid string, result CloudflareResult) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delivered := 0
+	for ch := range h.subscribers[uid] {
+		select {
+		case ch <- result:
+			delivered++
+		default:
+			fmt.Printf("Dropping update for slow subscriber on %s\n", uid)
+		}
+	}
+	return delivered
+}
+
+// writeSSEEvent writes a single named server-sent event with a JSON payload
+func writeSSEEvent(w *bufio.Writer, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func registerEventRoutes(app *fiber.App, config CloudflareConfig, hub *VideoHub, limiter *StreamLimiter) {
+	// Server-sent events stream of status updates for a single video
+	app.Get("/api/video/:uid/events", limitStreams(limiter), func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+
+		updates, unsubscribe := hub.Subscribe(uid)
+		releaseSlot := detachStreamSlot(c)
+
+		// The stream outlives the handler, so keep the request's values but not its deadline
+		ctx := context.WithoutCancel(c.UserContext())
+
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer unsubscribe()
+			defer releaseSlot()
+
+			// Send the current state first so a client that connects after the
+			// webhook already fired isn't left waiting forever
+			current, err := fetchVideo(ctx, config, uid)
+			if err != nil {
+				fmt.Printf("SSE initial status error for %s: %v\n", uid, err)
+			} else if current.Success {
+				if err := writeSSEEvent(w, "status", current.Result); err != nil {
+					return
+				}
+				if current.Result.ReadyToStream {
+					return
+				}
+			}
+
+			keepAlive := time.NewTicker(sseKeepAliveInterval)
+			defer keepAlive.Stop()
+
+			for {
+				select {
+				case result := <-updates:
+					if err := writeSSEEvent(w, "status", result); err != nil {
+						return
+					}
+					if result.ReadyToStream || result.Status.State == "error" {
+						return
+					}
+				case <-keepAlive.C:
+					// A failed write means the client has gone away
+					if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+						return
+					}
+					if err := w.Flush(); err != nil {
+						return
+					}
+				}
+			}
+		})
+
+		return nil
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// csvRow renders one video as a row matching the export header
+func csvRow(video CloudflareResult) []string {
+	return []string{
+		video.UID,
+		video.Meta.Name,
+		video.Status.State,
+		strconv.FormatFloat(video.Duration, 'f', -1, 64),
+		formatTimestamp(video.Created),
+		strconv.FormatBool(video.ReadyToStream),
+	}
+}
+
+func registerExportRoutes(app *fiber.App, config CloudflareConfig, timeout fiber.Handler) {
+	// Download the whole video inventory as CSV, streamed page by page
+	app.Get("/api/videos.csv", timeout, func(c *fiber.Ctx) error {
+		// Fetch the first page up front so failures still get a proper error status
+		first, err := listVideos(c.UserContext(), config, url.Values{})
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to list videos",
+				"details": err.Error(),
+			})
+		}
+		if !first.Success {
+			return respondCloudflareError(c, "List failed", first.Errors)
+		}
+
+		c.Set("Content-Type", "text/csv")
+		c.Set("Content-Disposition", `attachment; filename="videos.csv"`)
+
+		// The stream outlives the handler, so keep the request's values but not its deadline
+		ctx := context.WithoutCancel(c.UserContext())
+
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			out := csv.NewWriter(w)
+			out.Write([]string{"uid", "name", "state", "duration", "created", "ready"})
+
+			// The first page is flushed right away so the client sees data as it arrives
+			for _, video := range first.Result {
+				out.Write(csvRow(video))
+			}
+			out.Flush()
+			if err := w.Flush(); err != nil || len(first.Result) < cloudflarePageSize {
+				return
+			}
+
+			rest := url.Values{"end": {formatTimestamp(first.Result[len(first.Result)-1].Created)}}
+			err := iterateVideos(ctx, config, rest, func(video CloudflareResult) error {
+				out.Write(csvRow(video))
+				out.Flush()
+				return out.Error()
+			})
+			if err != nil {
+				fmt.Printf("CSV export stopped early: %v\n", err)
+			}
+			out.Flush()
+			w.Flush()
+		})
+
+		return nil
+	})
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// featureEnabled reads the FEATURE_<NAME> flag, falling back to def when unset
+func featureEnabled(name string, def bool) bool {
+	return envBool("FEATURE_"+strings.ToUpper(name), def)
+}
+
+// requireFeature hides routes behind a feature flag, answering 404 while it is
+// disabled so clients can't tell the route exists. The flag is read once at startup.
+func requireFeature(name string, def bool) fiber.Handler {
+	enabled := featureEnabled(name, def)
+	return func(c *fiber.Ctx) error {
+		if !enabled {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Not found",
+			})
+		}
+		return c.Next()
+	}
+}
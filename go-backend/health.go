@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// credentialStatus is the result of the most recent conclusive check of one account's token
+type credentialStatus struct {
+	healthy bool
+	reason  string
+}
+
+// CredentialHealth tracks whether each configured Cloudflare account's API token
+// is still valid. Accounts that have not been checked yet count as healthy.
+type CredentialHealth struct {
+	defaultAccount string
+	mu             sync.RWMutex
+	statuses       map[string]credentialStatus
+}
+
+func newCredentialHealth(defaultAccount string) *CredentialHealth {
+	return &CredentialHealth{
+		defaultAccount: defaultAccount,
+		statuses:       make(map[string]credentialStatus),
+	}
+}
+
+// status returns the latest conclusive result for accountID
+func (h *CredentialHealth) status(accountID string) credentialStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if status, ok := h.statuses[accountID]; ok {
+		return status
+	}
+	return credentialStatus{healthy: true}
+}
+
+// Healthy reports the result of the most recent conclusive check of the
+// default account, which the server itself runs on
+func (h *CredentialHealth) Healthy() bool {
+	return h.status(h.defaultAccount).healthy
+}
+
+// record stores a conclusive result and reports whether healthiness changed
+func (h *CredentialHealth) record(accountID string, status credentialStatus) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	previous, ok := h.statuses[accountID]
+	if !ok {
+		previous.healthy = true
+	}
+	h.statuses[accountID] = status
+	return previous.healthy != status.healthy
+}
+
+// verifyToken asks Cloudflare whether the API token is active. Only a 401 or 403,
+// or a successful answer whose token status isn't "active", marks the token
+// invalid; any other failure is returned as an error so the check counts as
+// inconclusive.
+func verifyToken(ctx context.Context, config CloudflareConfig) (bool, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", config.BaseURL+"/user/tokens/verify", nil)
+	if err != nil {
+		return false, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+config.APIToken)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return false, fmt.Sprintf("Cloudflare rejected the API token (HTTP %d)", resp.StatusCode), nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return false, "", fmt.Errorf("token verification returned HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Result struct {
+			Status string `json:"status"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, "", err
+	}
+
+	if result.Result.Status != "active" {
+		return false, fmt.Sprintf("API token status is %q", result.Result.Status), nil
+	}
+	return true, "", nil
+}
+
+// Run checks every account's credentials immediately and then on every interval
+// until ctx is cancelled. Inconclusive checks (network errors, unexpected
+// statuses) leave that account's previous state untouched.
+func (h *CredentialHealth) Run(ctx context.Context, accounts map[string]CloudflareConfig, interval time.Duration) {
+	check := func(config CloudflareConfig) {
+		checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		healthy, reason, err := verifyToken(checkCtx, config)
+		if err != nil {
+			// Checks cut short by shutdown aren't worth reporting
+			if ctx.Err() == nil {
+				fmt.Printf("Credential health check for account %s inconclusive: %v\n", config.AccountID, err)
+			}
+			return
+		}
+
+		if h.record(config.AccountID, credentialStatus{healthy: healthy, reason: reason}) {
+			if healthy {
+				fmt.Printf("Cloudflare credentials for account %s are valid again\n", config.AccountID)
+			} else {
+				fmt.Printf("Cloudflare credentials for account %s invalid: %s\n", config.AccountID, reason)
+			}
+		}
+	}
+	checkAll := func() {
+		for _, config := range accounts {
+			check(config)
+		}
+	}
+
+	checkAll()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkAll()
+		}
+	}
+}
+
+// requireHealthyCredentials fails fast with 503 while the token of the account
+// selected for the request is known to be invalid. It runs after tenant
+// selection; requests without a tenant account are checked against def.
+func requireHealthyCredentials(h *CredentialHealth, def CloudflareConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		status := h.status(accountConfig(c.UserContext(), def).AccountID)
+		if !status.healthy {
+			return c.Status(503).JSON(fiber.Map{
+				"error":   "Cloudflare credentials invalid",
+				"details": status.reason,
+			})
+		}
+		return c.Next()
+	}
+}
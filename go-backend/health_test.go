@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestVerifyToken(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		status      int
+		body        string
+		wantHealthy bool
+		wantErr     bool
+	}{
+		{"active", 200, `{"success":true,"result":{"status":"active"}}`, true, false},
+		{"disabled", 200, `{"success":true,"result":{"status":"disabled"}}`, false, false},
+		{"unauthorized", 401, `{"success":false,"errors":[{"code":1000,"message":"Invalid API Token"}]}`, false, false},
+		{"forbidden", 403, `{"success":false}`, false, false},
+		{"rate limited", 429, `{"success":false,"errors":[{"code":10000,"message":"Rate limited"}]}`, false, true},
+		{"server error", 500, `{"success":false}`, false, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := newFakeCloudflare(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				io.WriteString(w, tc.body)
+			}))
+
+			healthy, _, err := verifyToken(context.Background(), config)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tc.wantErr)
+			}
+			if healthy != tc.wantHealthy {
+				t.Errorf("healthy = %v, want %v", healthy, tc.wantHealthy)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newFakeCloudflare serves handler in place of Cloudflare's API until the test
+// ends and returns an account config pointing at it
+func newFakeCloudflare(t *testing.T, handler http.Handler) CloudflareConfig {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return CloudflareConfig{AccountID: "acc", APIToken: "token", BaseURL: server.URL}
+}
+
+// noTimeout stands in for a route's timeout handler, passing straight through
+func noTimeout(c *fiber.Ctx) error {
+	return c.Next()
+}
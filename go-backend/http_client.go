@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// httpClient is shared by every outbound call so connections to Cloudflare
+// are pooled and kept alive instead of being re-established per request
+var httpClient = &http.Client{}
+
+// HTTPClientConfig tunes the connection pool of the shared transport
+type HTTPClientConfig struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	Breaker             *CircuitBreaker
+}
+
+// newHTTPClient builds a client on a tuned transport. Nearly all traffic goes to
+// a single host (api.cloudflare.com), so the per-host idle limit matters most;
+// Go's default of 2 would churn connections under concurrent status calls.
+func newHTTPClient(cfg HTTPClientConfig) *http.Client {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	var rt http.RoundTripper = rateLimitTransport{next: transport}
+	if cfg.Breaker != nil {
+		rt = breakerTransport{next: rt, breaker: cfg.Breaker}
+	}
+	return &http.Client{Transport: tracingTransport{next: rt}}
+}
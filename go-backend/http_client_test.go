@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// benchmarkBurst is how many requests each benchmark operation sends at once,
+// like a listing page fetching the status of every video on it
+const benchmarkBurst = 16
+
+// benchmarkClient sends bursts of concurrent GETs through client, one burst per
+// operation, and reports how many connections the server had to accept per burst
+func benchmarkClient(b *testing.B, client *http.Client) {
+	var accepted atomic.Int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"success":true,"errors":[],"messages":[],"result":{}}`)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			accepted.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+	defer client.CloseIdleConnections()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for j := 0; j < benchmarkBurst; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Get(server.URL)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}()
+		}
+		wg.Wait()
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(accepted.Load())/float64(b.N), "conns/op")
+}
+
+// BenchmarkHTTPClient compares the pooled client main builds against Go's
+// default per-host idle limit of 2, which keeps only two connections of each
+// burst and redials the rest next time, and against no keep-alive at all.
+func BenchmarkHTTPClient(b *testing.B) {
+	b.Run("pooled", func(b *testing.B) {
+		benchmarkClient(b, newHTTPClient(HTTPClientConfig{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 32,
+			IdleConnTimeout:     90 * time.Second,
+		}))
+	})
+	b.Run("default-per-host", func(b *testing.B) {
+		benchmarkClient(b, newHTTPClient(HTTPClientConfig{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: http.DefaultMaxIdleConnsPerHost,
+			IdleConnTimeout:     90 * time.Second,
+		}))
+	})
+	b.Run("no-keepalive", func(b *testing.B) {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DisableKeepAlives = true
+		benchmarkClient(b, &http.Client{Transport: transport})
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// VideoInput describes the uploaded source file as Cloudflare measured it.
+// Fields Cloudflare hasn't reported are null.
+type VideoInput struct {
+	UID             string   `json:"uid"`
+	State           string   `json:"state"`
+	Width           *int     `json:"width"`
+	Height          *int     `json:"height"`
+	FPS             *float64 `json:"fps"`
+	Codec           *string  `json:"codec"`
+	DurationSeconds *float64 `json:"durationSeconds"`
+	// Pending is set while Cloudflare is still probing the source, as during
+	// early processing
+	Pending bool `json:"pending"`
+}
+
+// videoInput reads the source details from the video's raw payload. Cloudflare
+// reports -1 for dimensions and duration it doesn't know yet; fps and codec are
+// only present on some videos.
+func videoInput(video *VideoUploadResponse) VideoInput {
+	input := VideoInput{
+		UID:   video.Result.UID,
+		State: video.Result.Status.State,
+	}
+
+	var raw struct {
+		Result struct {
+			Input struct {
+				Width  *int     `json:"width"`
+				Height *int     `json:"height"`
+				FPS    *float64 `json:"fps"`
+				Codec  *string  `json:"codec"`
+			} `json:"input"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(video.Raw, &raw); err == nil {
+		details := raw.Result.Input
+		if details.Width != nil && *details.Width > 0 {
+			input.Width = details.Width
+		}
+		if details.Height != nil && *details.Height > 0 {
+			input.Height = details.Height
+		}
+		if details.FPS != nil && *details.FPS > 0 {
+			input.FPS = details.FPS
+		}
+		if details.Codec != nil && *details.Codec != "" {
+			input.Codec = details.Codec
+		}
+	}
+	if duration := video.Result.Duration; duration > 0 {
+		input.DurationSeconds = &duration
+	}
+
+	input.Pending = input.Width == nil || input.Height == nil || input.DurationSeconds == nil
+	return input
+}
+
+func registerInputRoutes(app *fiber.App, config CloudflareConfig, timeout fiber.Handler) {
+	// Source dimensions, frame rate, codec and duration for troubleshooting playback
+	app.Get("/api/video/:uid/input", timeout, func(c *fiber.Ctx) error {
+		video, err := fetchVideo(c.UserContext(), config, c.Params("uid"))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !video.Success {
+			return respondCloudflareError(c, "Failed to get video", video.Errors)
+		}
+
+		return respond(c, 200, videoInput(video))
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// JanitorConfig controls the background cleanup of stale videos
+type JanitorConfig struct {
+	Interval time.Duration
+	// ErrorAge is how long a video may sit in the error state before it is deleted
+	ErrorAge time.Duration
+}
+
+// janitorTarget reports why a video should be cleaned up, or "" to keep it
+func janitorTarget(video CloudflareResult, errorAge time.Duration, now time.Time) string {
+	if video.ScheduledDeletion != "" {
+		if at, err := time.Parse(time.RFC3339, video.ScheduledDeletion); err == nil && now.After(at) {
+			return "past scheduled deletion"
+		}
+	}
+	if video.Status.State == "error" {
+		if !video.Created.IsZero() && now.Sub(video.Created) > errorAge {
+			return fmt.Sprintf("in error state for over %s", errorAge)
+		}
+	}
+	return ""
+}
+
+// sweepVideos deletes every video janitorTarget selects and returns how many were removed
+func sweepVideos(ctx context.Context, config CloudflareConfig, errorAge time.Duration) (int, error) {
+	type target struct{ uid, reason string }
+
+	now := time.Now()
+	var targets []target
+	err := iterateVideos(ctx, config, url.Values{}, func(video CloudflareResult) error {
+		if reason := janitorTarget(video, errorAge, now); reason != "" {
+			targets = append(targets, target{video.UID, reason})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, t := range targets {
+		status, err := deleteResource(ctx, config, "/stream/"+t.uid)
+		if err != nil {
+			return deleted, err
+		}
+		if status >= 300 && status != 404 {
+			fmt.Printf("Janitor could not delete %s: Cloudflare returned %d\n", t.uid, status)
+			continue
+		}
+		fmt.Printf("Janitor deleted %s (%s)\n", t.uid, t.reason)
+		deleted++
+	}
+	return deleted, nil
+}
+
+// runJanitor sweeps on every interval until ctx is cancelled
+func runJanitor(ctx context.Context, config CloudflareConfig, janitor JanitorConfig) {
+	fmt.Printf("Janitor enabled: sweeping every %s, error age %s\n", janitor.Interval, janitor.ErrorAge)
+
+	ticker := time.NewTicker(janitor.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("Janitor stopped")
+			return
+		case <-ticker.C:
+			deleted, err := sweepVideos(ctx, config, janitor.ErrorAge)
+			if err != nil && ctx.Err() == nil {
+				fmt.Printf("Janitor sweep failed: %v\n", err)
+			}
+			if deleted > 0 {
+				fmt.Printf("Janitor removed %d video(s)\n", deleted)
+			}
+		}
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Upload modes selectable per request with ?mode=
+const (
+	uploadModeSync   = "sync"
+	uploadModeQueued = "queued"
+	// uploadModeAuto queues only while the circuit breaker reports Cloudflare as down
+	uploadModeAuto = "auto"
+)
+
+// uploadModes are the accepted values of ?mode= on uploads
+var uploadModes = map[string]bool{
+	uploadModeSync:   true,
+	uploadModeQueued: true,
+	uploadModeAuto:   true,
+}
+
+// Upload job states
+const (
+	jobQueued     = "queued"
+	jobProcessing = "processing"
+	jobSucceeded  = "succeeded"
+	jobFailed     = "failed"
+)
+
+// UploadJob is an upload accepted into the queue. The file is spooled to a
+// temporary path until it has been sent to Cloudflare.
+type UploadJob struct {
+	ID        string    `json:"id"`
+	State     string    `json:"state"`
+	Filename  string    `json:"filename"`
+	Attempts  int       `json:"attempts"`
+	UID       string    `json:"uid,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	path    string
+	size    int64
+	account CloudflareConfig
+	options UploadOptions
+}
+
+// UploadQueueConfig tunes the upload queue
+type UploadQueueConfig struct {
+	Size        int
+	Workers     int
+	MaxAttempts int
+	RetryDelay  time.Duration
+	Retention   time.Duration
+}
+
+// UploadQueue is a bounded in-memory queue of uploads, processed by a fixed pool
+// of workers that hold off while the circuit breaker is open and retry transient
+// failures. Jobs do not survive a restart.
+type UploadQueue struct {
+	cfg     UploadQueueConfig
+	breaker *CircuitBreaker
+	slots   *PrioritySemaphore
+	pending chan *UploadJob
+	// callback, when set, is notified of each finished upload
+	callback *UploadCallback
+
+	mu   sync.Mutex
+	jobs map[string]*UploadJob
+}
+
+// errQueueFull is returned by Enqueue when the queue is at capacity
+var errQueueFull = errors.New("upload queue is full")
+
+func newUploadQueue(cfg UploadQueueConfig, breaker *CircuitBreaker, slots *PrioritySemaphore, callback *UploadCallback) *UploadQueue {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	return &UploadQueue{
+		cfg:      cfg,
+		breaker:  breaker,
+		slots:    slots,
+		pending:  make(chan *UploadJob, cfg.Size),
+		callback: callback,
+		jobs:     make(map[string]*UploadJob),
+	}
+}
+
+// newJobID returns a random identifier for an upload job
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// spoolUpload copies an uploaded file to a temporary path the job owns, since
+// the request's own copy is gone once the handler returns. When tee is set the
+// file is written to it as well during the copy.
+func spoolUpload(file *multipart.FileHeader, tee io.Writer) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return "", err
+	}
+	var out io.Writer = dst
+	if tee != nil {
+		out = io.MultiWriter(dst, tee)
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
+
+// Enqueue spools the file and adds a job for it, returning a snapshot of the new
+// job. When the queue has no room it returns errQueueFull without spooling anything.
+func (q *UploadQueue) Enqueue(file *multipart.FileHeader, account CloudflareConfig, opts UploadOptions) (UploadJob, error) {
+	if len(q.pending) >= cap(q.pending) {
+		return UploadJob{}, errQueueFull
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return UploadJob{}, err
+	}
+	path, err := spoolUpload(file, nil)
+	if err != nil {
+		return UploadJob{}, err
+	}
+
+	now := time.Now()
+	job := &UploadJob{
+		ID:        id,
+		State:     jobQueued,
+		Filename:  file.Filename,
+		CreatedAt: now,
+		UpdatedAt: now,
+		path:      path,
+		size:      file.Size,
+		account:   account,
+		options:   opts,
+	}
+
+	snapshot := *job
+
+	q.mu.Lock()
+	q.pruneLocked(now)
+	q.jobs[id] = job
+	q.mu.Unlock()
+
+	select {
+	case q.pending <- job:
+		return snapshot, nil
+	default:
+		// Another request took the last place between the check and the send
+		q.mu.Lock()
+		delete(q.jobs, id)
+		q.mu.Unlock()
+		os.Remove(path)
+		return UploadJob{}, errQueueFull
+	}
+}
+
+// Get returns a snapshot of the job with the given ID
+func (q *UploadQueue) Get(id string) (UploadJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.pruneLocked(time.Now())
+	job, ok := q.jobs[id]
+	if !ok {
+		return UploadJob{}, false
+	}
+	return *job, true
+}
+
+// pruneLocked forgets finished jobs older than the retention period
+func (q *UploadQueue) pruneLocked(now time.Time) {
+	for id, job := range q.jobs {
+		finished := job.State == jobSucceeded || job.State == jobFailed
+		if finished && now.Sub(job.UpdatedAt) > q.cfg.Retention {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// update applies fn to the job under the queue lock
+func (q *UploadQueue) update(job *UploadJob, fn func(*UploadJob)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	fn(job)
+	job.UpdatedAt = time.Now()
+}
+
+// Run starts the workers and blocks until ctx is done
+func (q *UploadQueue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-q.pending:
+					q.process(ctx, job)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// process uploads a job's file, retrying transient failures with a growing delay
+func (q *UploadQueue) process(ctx context.Context, job *UploadJob) {
+	defer os.Remove(job.path)
+	ctx = withAccount(ctx, job.account)
+
+	var lastErr error
+	var lastUID string
+	for attempt := 1; attempt <= q.cfg.MaxAttempts; attempt++ {
+		// Don't spend attempts while Cloudflare is known to be down
+		if wait := q.breaker.RetryAfter(); wait > 0 {
+			if !sleepContext(ctx, wait) {
+				return
+			}
+		}
+
+		q.update(job, func(j *UploadJob) {
+			j.State = jobProcessing
+			j.Attempts = attempt
+		})
+
+		uid, retry, err := q.attempt(ctx, job)
+		if err == nil {
+			q.update(job, func(j *UploadJob) {
+				j.State = jobSucceeded
+				j.UID = uid
+				j.Error = ""
+			})
+			fmt.Printf("Upload job %s finished as %s\n", job.ID, uid)
+			return
+		}
+
+		lastErr, lastUID = err, uid
+		fmt.Printf("Upload job %s attempt %d failed: %v\n", job.ID, attempt, err)
+		if !retry || attempt == q.cfg.MaxAttempts {
+			break
+		}
+		q.update(job, func(j *UploadJob) {
+			j.State = jobQueued
+			j.Error = err.Error()
+		})
+		if !sleepContext(ctx, q.cfg.RetryDelay*time.Duration(attempt)) {
+			return
+		}
+	}
+
+	q.update(job, func(j *UploadJob) {
+		j.State = jobFailed
+		j.UID = lastUID
+		j.Error = lastErr.Error()
+	})
+}
+
+// attempt sends the job's file to Cloudflare once and reports whether a failure
+// is worth retrying
+func (q *UploadQueue) attempt(ctx context.Context, job *UploadJob) (uid string, retry bool, err error) {
+	start := time.Now()
+	if err := q.slots.Acquire(ctx, job.options.Priority == "high"); err != nil {
+		return "", false, err
+	}
+	defer q.slots.Release()
+
+	uploadStart := time.Now()
+	result, streamed, err := uploadSpooledFile(ctx, job.account, job.path, job.Filename, job.size)
+	timing := UploadTiming{Bytes: streamed, Upload: time.Since(uploadStart)}
+	defer func() {
+		timing.Total = time.Since(start)
+		entry := UploadLogEntry{Source: "queued", Filename: job.Filename, UID: uid, Success: err == nil}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		recordUpload(entry, timing)
+	}()
+	if err != nil {
+		// Network errors and an open circuit are transient
+		return "", true, err
+	}
+	if !result.Success {
+		status := cloudflareErrorStatus(result.Errors)
+		return "", status == 429 || status >= 500, fmt.Errorf("upload failed: %v", result.Errors)
+	}
+
+	if mismatch := uploadSizeMismatch(job.size, streamed, result.Result.Size); mismatch != "" {
+		if _, err := deleteResource(ctx, job.account, "/stream/"+result.Result.UID); err != nil {
+			fmt.Printf("Could not delete incomplete video %s: %v\n", result.Result.UID, err)
+		}
+		return "", true, fmt.Errorf("upload was incomplete: %s", mismatch)
+	}
+
+	if err := finalizeUpload(ctx, job.account, result, job.options); err != nil {
+		// A video that should be private but isn't must not be reported as done
+		if job.options.RequireSigned {
+			return result.Result.UID, false, fmt.Errorf("video %s uploaded but could not be made private: %w", result.Result.UID, err)
+		}
+		fmt.Printf("Could not update %s with priority %s: %v\n", result.Result.UID, job.options.Priority, err)
+	}
+	q.callback.Notify(result, "queued", job.Filename)
+	return result.Result.UID, false, nil
+}
+
+// uploadSpooledFile streams a file on disk to Cloudflare's basic upload and
+// returns the decoded response along with the number of bytes streamed
+func uploadSpooledFile(ctx context.Context, config CloudflareConfig, path, filename string, size int64) (*VideoUploadResponse, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	streamed := &countingReader{r: file}
+	body, contentType, writeDone := newMultipartUploadBody(streamed, filename)
+	defer body.Close()
+
+	req, err := newCloudflareRequest(ctx, config, "POST", "/stream", body)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.ContentLength = multipartContentLength(contentType, filename, size)
+
+	resp, err := httpClient.Do(req)
+	// Closing the pipe unblocks the writer so its goroutine always exits
+	body.Close()
+	writeErr := <-writeDone
+	if err != nil {
+		return nil, streamed.n, err
+	}
+	defer resp.Body.Close()
+	if writeErr != nil && !errors.Is(writeErr, io.ErrClosedPipe) {
+		return nil, streamed.n, writeErr
+	}
+
+	var result VideoUploadResponse
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, streamed.n, err
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, streamed.n, err
+	}
+	return &result, streamed.n, nil
+}
+
+// sleepContext waits for d, returning false if ctx ends first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// resolveUploadMode picks sync or queued for a request. Auto queues only while
+// the breaker is open, so uploads ride out an outage instead of failing.
+func resolveUploadMode(mode string, breaker *CircuitBreaker) string {
+	if mode == uploadModeAuto {
+		if breaker.RetryAfter() > 0 {
+			return uploadModeQueued
+		}
+		return uploadModeSync
+	}
+	return mode
+}
+
+// bypassesCircuit reports whether a request keeps working while the circuit is
+// open: queued uploads and job lookups never call Cloudflare inline
+func bypassesCircuit(queue *UploadQueue, defaultMode string, breaker *CircuitBreaker) func(*fiber.Ctx) bool {
+	return func(c *fiber.Ctx) bool {
+		if queue == nil {
+			return false
+		}
+		if c.Method() == fiber.MethodGet && strings.HasPrefix(c.Path(), "/api/jobs/") {
+			return true
+		}
+		return c.Method() == fiber.MethodPost && c.Path() == "/api/upload" &&
+			resolveUploadMode(c.Query("mode", defaultMode), breaker) == uploadModeQueued
+	}
+}
+
+// respondQueueFull answers 429 when the queue has no room, asking the client to
+// back off for one retry delay before trying again
+func respondQueueFull(c *fiber.Ctx, retryDelay time.Duration) error {
+	seconds := int(retryDelay.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Set("Retry-After", strconv.Itoa(seconds))
+	return c.Status(429).JSON(fiber.Map{
+		"error":      "Upload queue is full, try again later",
+		"retryAfter": seconds,
+	})
+}
+
+func registerJobRoutes(app *fiber.App, queue *UploadQueue, timeout fiber.Handler) {
+	// Check on a queued upload
+	app.Get("/api/jobs/:id", timeout, func(c *fiber.Ctx) error {
+		if queue == nil {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Upload queue is disabled",
+			})
+		}
+		job, ok := queue.Get(c.Params("id"))
+		if !ok {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Job not found",
+			})
+		}
+		return respond(c, 200, job)
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Labels are stored in the video's meta as one comma-separated "labels" value,
+// since Cloudflare meta only holds strings
+const (
+	maxLabels      = 20
+	maxLabelLength = 64
+)
+
+// parseLabels normalises labels from form values, each of which may itself be a
+// comma-separated list. Labels are trimmed, lower-cased and de-duplicated.
+func parseLabels(values []string) ([]string, error) {
+	var labels []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		for _, label := range strings.Split(value, ",") {
+			label = strings.ToLower(strings.TrimSpace(label))
+			if label == "" || seen[label] {
+				continue
+			}
+			if len(label) > maxLabelLength {
+				return nil, fmt.Errorf("label %q is longer than %d characters", label, maxLabelLength)
+			}
+			seen[label] = true
+			labels = append(labels, label)
+		}
+	}
+	if len(labels) > maxLabels {
+		return nil, fmt.Errorf("at most %d labels are allowed", maxLabels)
+	}
+	return labels, nil
+}
+
+// joinLabels renders labels as the meta value
+func joinLabels(labels []string) string {
+	return strings.Join(labels, ",")
+}
+
+// splitLabels reads labels back from the meta value
+func splitLabels(meta string) []string {
+	if meta == "" {
+		return nil
+	}
+	return strings.Split(meta, ",")
+}
+
+// hasLabel reports whether a video's meta value includes the label
+func hasLabel(meta, label string) bool {
+	for _, l := range splitLabels(meta) {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Legacy top-level fields older integrations send with meta updates. They are
+// not kept as meta: together they are translated into the video's accessRules.
+const (
+	legacyAllowedCountries = "allowedCountries"
+	legacyBlockedCountries = "blockedCountries"
+)
+
+// parseLegacyCountries reads one legacy country list, upper-casing each code and
+// checking it is an ISO 3166-1 alpha-2 code. null means an empty list.
+func parseLegacyCountries(field string, value interface{}) ([]string, []FieldError) {
+	if value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, []FieldError{{Field: field, Error: "must be an array of ISO 3166-1 alpha-2 country codes"}}
+	}
+
+	countries := make([]string, 0, len(items))
+	var errs []FieldError
+	for i, item := range items {
+		code, _ := item.(string)
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if validate.Var(code, "iso3166_1_alpha2") != nil {
+			errs = append(errs, FieldError{
+				Field: fmt.Sprintf("%s[%d]", field, i),
+				Error: fmt.Sprintf("%v is not an ISO 3166-1 alpha-2 country code", item),
+			})
+			continue
+		}
+		countries = append(countries, code)
+	}
+	return countries, errs
+}
+
+// legacyCountryRules removes allowedCountries and blockedCountries from a meta
+// update body and returns the access rules they describe. present is false when
+// neither field was sent. Blocked countries are refused first; when allowed
+// countries are given, they are let through and everyone else is blocked. The
+// two fields describe the whole rule set, so one sent alone clears the other.
+func legacyCountryRules(body map[string]interface{}) (rules []AccessRule, present bool, errs []FieldError) {
+	allowedValue, hasAllowed := body[legacyAllowedCountries]
+	blockedValue, hasBlocked := body[legacyBlockedCountries]
+	if !hasAllowed && !hasBlocked {
+		return nil, false, nil
+	}
+	delete(body, legacyAllowedCountries)
+	delete(body, legacyBlockedCountries)
+
+	allowed, allowedErrs := parseLegacyCountries(legacyAllowedCountries, allowedValue)
+	blocked, blockedErrs := parseLegacyCountries(legacyBlockedCountries, blockedValue)
+	if errs = append(allowedErrs, blockedErrs...); len(errs) > 0 {
+		return nil, true, errs
+	}
+
+	rules = []AccessRule{}
+	if len(blocked) > 0 {
+		rules = append(rules, AccessRule{Type: "ip.geoip.country", Action: "block", Country: blocked})
+	}
+	if len(allowed) > 0 {
+		rules = append(rules,
+			AccessRule{Type: "ip.geoip.country", Action: "allow", Country: allowed},
+			AccessRule{Type: "any", Action: "block"},
+		)
+	}
+	return normalizeAccessRules(rules), true, nil
+}
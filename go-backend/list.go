@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// cloudflarePageSize is the most videos Cloudflare returns from a single list call
+	cloudflarePageSize = 1000
+	defaultListLimit   = 50
+
+	// maxIteratedVideos bounds how far iterateVideos walks before giving up
+	maxIteratedVideos = 100000
+)
+
+// errTooManyVideos is returned when iterateVideos hits maxIteratedVideos
+var errTooManyVideos = errors.New("stopped listing videos after reaching the iteration limit")
+
+// errPageFilled stops iterateVideos once a filtered page has enough videos
+var errPageFilled = errors.New("page filled")
+
+// VideoListResponse represents Cloudflare's response when listing videos
+type VideoListResponse struct {
+	Result   []CloudflareResult `json:"result"`
+	Success  bool               `json:"success"`
+	Errors   []CloudflareError  `json:"errors"`
+	Messages []string           `json:"messages"`
+}
+
+// listCursor is a position in a newest-first listing: the creation time and UID
+// of the last item returned. Items created at the same instant are ordered by
+// UID, descending, so ties straddling a page boundary are neither skipped nor
+// repeated.
+type listCursor struct {
+	Created time.Time
+	UID     string
+}
+
+// follows reports whether an item created at created with the given UID comes
+// after the cursor in listing order
+func (c listCursor) follows(created time.Time, uid string) bool {
+	return created.Before(c.Created) || (created.Equal(c.Created) && uid < c.UID)
+}
+
+// end is Cloudflare's exclusive end bound for the rest of the listing. It lies
+// just past the cursor's creation time so videos tied with the cursor are
+// fetched again and can be told apart by UID.
+func (c listCursor) end() time.Time {
+	return c.Created.Add(time.Nanosecond)
+}
+
+// encodeCursor turns the position of the last item on a page into an opaque token
+func encodeCursor(cursor listCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(formatTimestamp(cursor.Created) + "|" + cursor.UID))
+}
+
+// decodeCursor reverses encodeCursor, rejecting tokens that don't hold a
+// timestamp. Tokens from before UIDs were included decode with an empty UID,
+// which still resumes strictly before their timestamp.
+func decodeCursor(token string) (listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return listCursor{}, errors.New("cursor is not a valid token")
+	}
+	created, uid, _ := strings.Cut(string(raw), "|")
+	t, err := time.Parse(time.RFC3339Nano, created)
+	if err != nil {
+		return listCursor{}, errors.New("cursor is not a valid token")
+	}
+	return listCursor{Created: t, UID: uid}, nil
+}
+
+// videoCursor is the cursor positioned at video
+func videoCursor(video CloudflareResult) listCursor {
+	return listCursor{Created: video.Created, UID: video.UID}
+}
+
+// sortNewestFirst puts videos in listing order: newest first, ties by UID descending
+func sortNewestFirst(videos []CloudflareResult) {
+	sort.Slice(videos, func(i, j int) bool {
+		return videoCursor(videos[i]).follows(videos[j].Created, videos[j].UID)
+	})
+}
+
+// parseCreatedRange reads the optional ?created_after= and ?created_before=
+// bounds (RFC3339), either of which may be zero, and checks after < before
+func parseCreatedRange(c *fiber.Ctx) (after, before time.Time, err error) {
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+	}{{"created_after", &after}, {"created_before", &before}} {
+		value := c.Query(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%s must be an RFC3339 timestamp such as 2024-01-02T15:04:05Z", bound.name)
+		}
+		*bound.dst = t
+	}
+	if !after.IsZero() && !before.IsZero() && !after.Before(before) {
+		return time.Time{}, time.Time{}, errors.New("created_after must be before created_before")
+	}
+	return after, before, nil
+}
+
+// listVideos fetches one page of videos created before the given time (newest first)
+func listVideos(ctx context.Context, config CloudflareConfig, params url.Values) (*VideoListResponse, error) {
+	path := "/stream"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	return callCloudflare[VideoListResponse](ctx, config, "GET", path, nil)
+}
+
+// listLabeledVideos collects up to limit+1 videos carrying the label that come
+// after position (nil for the first page), walking as many Cloudflare pages as
+// it takes. Cloudflare can't filter on meta, so the match happens here; the
+// extra video tells the caller another page exists.
+func listLabeledVideos(ctx context.Context, config CloudflareConfig, params url.Values, position *listCursor, label string, limit int) ([]CloudflareResult, error) {
+	matches := []CloudflareResult{}
+	err := iterateVideos(ctx, config, params, func(video CloudflareResult) error {
+		if position != nil && !position.follows(video.Created, video.UID) {
+			return nil
+		}
+		if hasLabel(video.Meta.Labels, label) {
+			matches = append(matches, video)
+			if len(matches) > limit {
+				return errPageFilled
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errPageFilled) {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// iterateVideos calls fn for every video matching params, following Cloudflare's
+// time-window pagination until a short page shows the listing is exhausted.
+// Iteration stops at the first error returned by fn or by Cloudflare.
+func iterateVideos(ctx context.Context, config CloudflareConfig, params url.Values, fn func(CloudflareResult) error) error {
+	query := url.Values{}
+	for key, values := range params {
+		query[key] = values
+	}
+
+	seen := 0
+	var position *listCursor
+	for {
+		page, err := listVideos(ctx, config, query)
+		if err != nil {
+			return err
+		}
+		if !page.Success {
+			return fmt.Errorf("list failed: %v", page.Errors)
+		}
+
+		// Each page after the first repeats the videos tied with the last one seen
+		sortNewestFirst(page.Result)
+		advanced := false
+		for _, video := range page.Result {
+			if position != nil && !position.follows(video.Created, video.UID) {
+				continue
+			}
+			if seen >= maxIteratedVideos {
+				return errTooManyVideos
+			}
+			seen++
+			if err := fn(video); err != nil {
+				return err
+			}
+			cursor := videoCursor(video)
+			position, advanced = &cursor, true
+		}
+
+		// A full page of nothing new would only repeat itself
+		if len(page.Result) < cloudflarePageSize || !advanced {
+			return nil
+		}
+		query.Set("end", formatTimestamp(position.end()))
+	}
+}
+
+func registerListRoutes(app *fiber.App, config CloudflareConfig, timeout fiber.Handler) {
+	// List videos with an opaque cursor for infinite scroll, optionally only those
+	// with ?label= or created within ?created_after= and ?created_before=
+	app.Get("/api/videos", timeout, func(c *fiber.Ctx) error {
+		limit := c.QueryInt("limit", defaultListLimit)
+		if limit < 1 || limit > cloudflarePageSize {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "limit must be between 1 and 1000",
+			})
+		}
+
+		after, before, err := parseCreatedRange(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid date range",
+				"details": err.Error(),
+			})
+		}
+
+		// Translate the range and cursor into Cloudflare's time-window parameters.
+		// The start bound stays on every page so pagination never leaves the window.
+		params := url.Values{}
+		if !after.IsZero() {
+			params.Set("start", formatTimestamp(after.UTC()))
+		}
+		if !before.IsZero() {
+			params.Set("end", formatTimestamp(before.UTC()))
+		}
+		var position *listCursor
+		if token := c.Query("cursor"); token != "" {
+			cursor, err := decodeCursor(token)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{
+					"error":   "Invalid cursor",
+					"details": err.Error(),
+				})
+			}
+			position = &cursor
+			// A cursor from another query may point past the window's end; keep the earlier bound
+			if before.IsZero() || cursor.end().Before(before) {
+				params.Set("end", formatTimestamp(cursor.end().UTC()))
+			}
+		}
+		if search := c.Query("search"); search != "" {
+			params.Set("search", search)
+		}
+
+		var videos []CloudflareResult
+		var hasMore bool
+		if label := strings.ToLower(strings.TrimSpace(c.Query("label"))); label != "" {
+			matches, err := listLabeledVideos(c.UserContext(), config, params, position, label, limit)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Failed to list videos",
+					"details": err.Error(),
+				})
+			}
+			videos = matches
+			hasMore = len(videos) > limit
+		} else {
+			result, err := listVideos(c.UserContext(), config, params)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Failed to list videos",
+					"details": err.Error(),
+				})
+			}
+
+			if !result.Success {
+				return respondCloudflareError(c, "List failed", result.Errors)
+			}
+
+			// The page starts with the videos tied with the cursor, already returned
+			sortNewestFirst(result.Result)
+			for _, video := range result.Result {
+				if position == nil || position.follows(video.Created, video.UID) {
+					videos = append(videos, video)
+				}
+			}
+			hasMore = len(videos) > limit || len(result.Result) == cloudflarePageSize
+		}
+		if len(videos) > limit {
+			videos = videos[:limit]
+		}
+
+		nextCursor := ""
+		if hasMore && len(videos) > 0 {
+			nextCursor = encodeCursor(videoCursor(videos[len(videos)-1]))
+		}
+
+		return respondList(c, videos, Pagination{
+			Limit:      limit,
+			Count:      len(videos),
+			HasMore:    nextCursor != "",
+			NextCursor: nextCursor,
+		})
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestListVideosPaginatesThroughCreationTies(t *testing.T) {
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	videos := []CloudflareResult{
+		{UID: "e", Created: base.Add(time.Minute)},
+		{UID: "a", Created: base},
+		{UID: "c", Created: base},
+		{UID: "b", Created: base},
+		{UID: "d", Created: base.Add(-time.Minute)},
+	}
+	for i := range videos {
+		videos[i].Meta.Labels = "demo"
+	}
+
+	// Cloudflare lists newest first, only videos created strictly before ?end=
+	config := newFakeCloudflare(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := []CloudflareResult{}
+		end, _ := time.Parse(time.RFC3339Nano, r.URL.Query().Get("end"))
+		for _, video := range videos {
+			if end.IsZero() || video.Created.Before(end) {
+				page = append(page, video)
+			}
+		}
+		json.NewEncoder(w).Encode(VideoListResponse{Result: page, Success: true})
+	}))
+
+	app := fiber.New()
+	registerListRoutes(app, config, noTimeout)
+
+	for _, query := range []string{"limit=2", "limit=2&label=demo"} {
+		var uids []string
+		cursor := ""
+		for pages := 0; pages < 10; pages++ {
+			target := "/api/videos?" + query
+			if cursor != "" {
+				target += "&cursor=" + url.QueryEscape(cursor)
+			}
+			resp, err := app.Test(httptest.NewRequest("GET", target, nil))
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			var body struct {
+				Result     []CloudflareResult `json:"result"`
+				NextCursor string             `json:"nextCursor"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("%s: decode: %v", query, err)
+			}
+			for _, video := range body.Result {
+				uids = append(uids, video.UID)
+			}
+			if cursor = body.NextCursor; cursor == "" {
+				break
+			}
+		}
+
+		want := []string{"e", "c", "b", "a", "d"}
+		if len(uids) != len(want) {
+			t.Fatalf("%s: listed %v, want %v", query, uids, want)
+		}
+		for i := range want {
+			if uids[i] != want[i] {
+				t.Fatalf("%s: listed %v, want %v", query, uids, want)
+			}
+		}
+	}
+}
+
+func TestSortNewestFirstBreaksTiesByUID(t *testing.T) {
+	base := time.Now()
+	videos := []CloudflareResult{
+		{UID: "a", Created: base},
+		{UID: "old", Created: base.Add(-time.Hour)},
+		{UID: "b", Created: base},
+	}
+	sortNewestFirst(videos)
+	if got := videos[0].UID + videos[1].UID + videos[2].UID; got != "baold" {
+		t.Errorf("order = %q, want b, a, old", got)
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// redactedSecret replaces stream keys and other ingest credentials in responses
+const redactedSecret = "[redacted]"
+
+// liveDetailConcurrency bounds the per-input detail lookups made while listing
+const liveDetailConcurrency = 5
+
+// recordingModes are the recording modes Cloudflare accepts for a live input
+var recordingModes = map[string]bool{
+	"automatic": true,
+	"off":       true,
+}
+
+// CreateLiveInputRequest is the body accepted when creating a live input
+type CreateLiveInputRequest struct {
+	Name              string `json:"name"`
+	RecordingMode     string `json:"recordingMode"`
+	RequireSignedURLs *bool  `json:"requireSignedURLs"`
+	TimeoutSeconds    *int   `json:"timeoutSeconds"`
+}
+
+// LiveInputRecording holds the recording settings of a live input
+type LiveInputRecording struct {
+	Mode              string `json:"mode"`
+	RequireSignedURLs bool   `json:"requireSignedURLs"`
+	TimeoutSeconds    int    `json:"timeoutSeconds"`
+}
+
+// LiveInput represents a single live input as returned by Cloudflare
+type LiveInput struct {
+	UID      string `json:"uid"`
+	Created  string `json:"created"`
+	Modified string `json:"modified"`
+	Meta     struct {
+		Name string `json:"name"`
+	} `json:"meta"`
+	Recording LiveInputRecording `json:"recording"`
+	RTMPS     struct {
+		URL       string `json:"url"`
+		StreamKey string `json:"streamKey"`
+	} `json:"rtmps"`
+	SRT struct {
+		URL        string `json:"url"`
+		StreamID   string `json:"streamId"`
+		Passphrase string `json:"passphrase"`
+	} `json:"srt"`
+	WebRTC struct {
+		URL string `json:"url"`
+	} `json:"webRTC"`
+	Status *struct {
+		Current struct {
+			State string `json:"state"`
+		} `json:"current"`
+	} `json:"status"`
+}
+
+// LiveInputResponse represents Cloudflare's response for a single live input
+type LiveInputResponse struct {
+	Result   LiveInput         `json:"result"`
+	Success  bool              `json:"success"`
+	Errors   []CloudflareError `json:"errors"`
+	Messages []string          `json:"messages"`
+}
+
+// LiveInputListResponse represents Cloudflare's response when listing live inputs
+type LiveInputListResponse struct {
+	Result struct {
+		LiveInputs []LiveInput `json:"liveInputs"`
+	} `json:"result"`
+	Success  bool              `json:"success"`
+	Errors   []CloudflareError `json:"errors"`
+	Messages []string          `json:"messages"`
+}
+
+// LiveInputSummary is the shape returned to clients for each live input
+type LiveInputSummary struct {
+	UID           string `json:"uid"`
+	Name          string `json:"name"`
+	Created       string `json:"created"`
+	RecordingMode string `json:"recordingMode"`
+	Connection    string `json:"connection"`
+	Ingest        struct {
+		RTMPS struct {
+			URL       string `json:"url"`
+			StreamKey string `json:"streamKey"`
+		} `json:"rtmps"`
+		SRT struct {
+			URL        string `json:"url"`
+			StreamID   string `json:"streamId"`
+			Passphrase string `json:"passphrase"`
+		} `json:"srt"`
+	} `json:"ingest"`
+}
+
+// liveInputCursor is the list cursor positioned at input
+func liveInputCursor(input LiveInput) listCursor {
+	created, _ := time.Parse(time.RFC3339Nano, input.Created)
+	return listCursor{Created: created, UID: input.UID}
+}
+
+// summarizeLiveInput flattens a live input, redacting ingest credentials unless reveal is set
+func summarizeLiveInput(input LiveInput, reveal bool) LiveInputSummary {
+	summary := LiveInputSummary{
+		UID:           input.UID,
+		Name:          input.Meta.Name,
+		Created:       input.Created,
+		RecordingMode: input.Recording.Mode,
+		Connection:    "disconnected",
+	}
+	if input.Status != nil && input.Status.Current.State != "" {
+		summary.Connection = input.Status.Current.State
+	}
+
+	summary.Ingest.RTMPS.URL = input.RTMPS.URL
+	summary.Ingest.RTMPS.StreamKey = input.RTMPS.StreamKey
+	summary.Ingest.SRT.URL = input.SRT.URL
+	summary.Ingest.SRT.StreamID = input.SRT.StreamID
+	summary.Ingest.SRT.Passphrase = input.SRT.Passphrase
+
+	if !reveal {
+		if summary.Ingest.RTMPS.StreamKey != "" {
+			summary.Ingest.RTMPS.StreamKey = redactedSecret
+		}
+		if summary.Ingest.SRT.StreamID != "" {
+			summary.Ingest.SRT.StreamID = redactedSecret
+		}
+		if summary.Ingest.SRT.Passphrase != "" {
+			summary.Ingest.SRT.Passphrase = redactedSecret
+		}
+	}
+
+	return summary
+}
+
+// fetchLiveInput retrieves the full details (ingest endpoints, status) of one live input
+func fetchLiveInput(ctx context.Context, config CloudflareConfig, uid string) (*LiveInputResponse, error) {
+	return callCloudflare[LiveInputResponse](ctx, config, "GET", "/stream/live_inputs/"+uid, nil)
+}
+
+// listLiveInputs retrieves every live input on the account (without ingest details)
+func listLiveInputs(ctx context.Context, config CloudflareConfig) (*LiveInputListResponse, error) {
+	return callCloudflare[LiveInputListResponse](ctx, config, "GET", "/stream/live_inputs", nil)
+}
+
+// listLiveInputVideos retrieves the recordings created from a live input
+func listLiveInputVideos(ctx context.Context, config CloudflareConfig, uid string) (*VideoListResponse, error) {
+	return callCloudflare[VideoListResponse](ctx, config, "GET", "/stream/live_inputs/"+uid+"/videos", nil)
+}
+
+func registerLiveRoutes(app *fiber.App, config CloudflareConfig, defaultRecordingMode string, timeout fiber.Handler) {
+	// Create a live input, applying the configured recording mode unless overridden
+	app.Post("/api/live", timeout, requireJSON(), func(c *fiber.Ctx) error {
+		var body CreateLiveInputRequest
+		if len(c.Body()) > 0 {
+			if ok, err := bindBody(c, &body); !ok {
+				return err
+			}
+		}
+
+		mode := body.RecordingMode
+		if mode == "" {
+			mode = defaultRecordingMode
+		}
+		if !recordingModes[mode] {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid recording mode",
+				"details": "recordingMode must be one of automatic, off",
+			})
+		}
+
+		recording := fiber.Map{"mode": mode}
+		if body.RequireSignedURLs != nil {
+			recording["requireSignedURLs"] = *body.RequireSignedURLs
+		}
+		if body.TimeoutSeconds != nil {
+			if *body.TimeoutSeconds < 0 {
+				return c.Status(400).JSON(fiber.Map{
+					"error": "timeoutSeconds must not be negative",
+				})
+			}
+			recording["timeoutSeconds"] = *body.TimeoutSeconds
+		}
+
+		payload := fiber.Map{"recording": recording}
+		if body.Name != "" {
+			payload["meta"] = fiber.Map{"name": body.Name}
+		}
+
+		result, err := callCloudflare[LiveInputResponse](c.UserContext(), config, "POST", "/stream/live_inputs", payload)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to create live input",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Live input creation failed", result.Errors)
+		}
+
+		// The creator needs the stream key once, so it is not redacted here
+		return respondResult(c, 201, summarizeLiveInput(result.Result, true), fiber.Map{
+			"requireSignedURLs": result.Result.Recording.RequireSignedURLs,
+			"timeoutSeconds":    result.Result.Recording.TimeoutSeconds,
+		})
+	})
+
+	// List live inputs with their ingest endpoints and connection state
+	app.Get("/api/live", timeout, func(c *fiber.Ctx) error {
+		limit := c.QueryInt("limit", defaultListLimit)
+		if limit < 1 || limit > cloudflarePageSize {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "limit must be between 1 and 1000",
+			})
+		}
+		reveal := c.QueryBool("reveal", false)
+
+		var position *listCursor
+		if token := c.Query("cursor"); token != "" {
+			cursor, err := decodeCursor(token)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{
+					"error":   "Invalid cursor",
+					"details": err.Error(),
+				})
+			}
+			position = &cursor
+		}
+
+		list, err := listLiveInputs(c.UserContext(), config)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to list live inputs",
+				"details": err.Error(),
+			})
+		}
+		if !list.Success {
+			return respondCloudflareError(c, "List failed", list.Errors)
+		}
+
+		// Cloudflare returns every input at once, so page through them newest first
+		// using the same creation-time and UID cursor as the video list
+		inputs := list.Result.LiveInputs
+		sort.Slice(inputs, func(i, j int) bool {
+			return liveInputCursor(inputs[i]).follows(liveInputCursor(inputs[j]).Created, inputs[j].UID)
+		})
+		page := make([]LiveInput, 0, limit)
+		for _, input := range inputs {
+			if position != nil && !position.follows(liveInputCursor(input).Created, input.UID) {
+				continue
+			}
+			page = append(page, input)
+		}
+		hasMore := len(page) > limit
+		if hasMore {
+			page = page[:limit]
+		}
+
+		// The list omits ingest endpoints and status, so fetch each input's details
+		summaries := make([]LiveInputSummary, len(page))
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, liveDetailConcurrency)
+		for i, input := range page {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func(i int, input LiveInput) {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				if details, err := fetchLiveInput(c.UserContext(), config, input.UID); err == nil && details.Success {
+					input = details.Result
+				}
+				summaries[i] = summarizeLiveInput(input, reveal)
+			}(i, input)
+		}
+		wg.Wait()
+
+		nextCursor := ""
+		if hasMore && len(page) > 0 {
+			nextCursor = encodeCursor(liveInputCursor(page[len(page)-1]))
+		}
+
+		return respondList(c, summaries, Pagination{
+			Limit:      limit,
+			Count:      len(summaries),
+			HasMore:    nextCursor != "",
+			NextCursor: nextCursor,
+		})
+	})
+
+	// Delete a live input, optionally removing its recordings first
+	app.Delete("/api/live/:uid", timeout, func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		input, err := fetchLiveInput(c.UserContext(), config, uid)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get live input",
+				"details": err.Error(),
+			})
+		}
+		if !input.Success {
+			return respondCloudflareError(c, "Live input not found", input.Errors)
+		}
+
+		deletedRecordings := []string{}
+		failedRecordings := fiber.Map{}
+		if c.QueryBool("deleteRecordings", false) {
+			recordings, err := listLiveInputVideos(c.UserContext(), config, uid)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Failed to list recordings",
+					"details": err.Error(),
+				})
+			}
+			if !recordings.Success {
+				return respondCloudflareError(c, "Failed to list recordings", recordings.Errors)
+			}
+
+			for _, video := range recordings.Result {
+				status, err := deleteResource(c.UserContext(), config, "/stream/"+video.UID)
+				switch {
+				case err != nil:
+					failedRecordings[video.UID] = err.Error()
+				case status >= 300 && status != 404:
+					failedRecordings[video.UID] = fmt.Sprintf("Cloudflare returned HTTP %d", status)
+				default:
+					deletedRecordings = append(deletedRecordings, video.UID)
+				}
+			}
+
+			// Keep the live input if any recording survived so the caller can retry
+			if len(failedRecordings) > 0 {
+				return c.Status(502).JSON(fiber.Map{
+					"error":             "Some recordings could not be deleted, live input kept",
+					"deletedRecordings": deletedRecordings,
+					"failedRecordings":  failedRecordings,
+				})
+			}
+		}
+
+		status, err := deleteResource(c.UserContext(), config, "/stream/live_inputs/"+uid)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to delete live input",
+				"details": err.Error(),
+			})
+		}
+		if status == 404 {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Live input not found",
+			})
+		}
+		if status >= 300 {
+			return c.Status(502).JSON(fiber.Map{
+				"error":             "Cloudflare refused to delete the live input",
+				"status":            status,
+				"deletedRecordings": deletedRecordings,
+			})
+		}
+
+		return respond(c, 200, fiber.Map{
+			"deletedLiveInput":  uid,
+			"deletedRecordings": deletedRecordings,
+		})
+	})
+}
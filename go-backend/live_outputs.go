@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// outputSchemes are the protocols Cloudflare can restream a live input over
+var outputSchemes = map[string]bool{
+	"rtmp":  true,
+	"rtmps": true,
+}
+
+// CreateLiveOutputRequest is the body accepted when adding a restream output
+type CreateLiveOutputRequest struct {
+	URL       string `json:"url" validate:"required,max=2048"`
+	StreamKey string `json:"streamKey" validate:"required,max=512"`
+	Enabled   *bool  `json:"enabled"`
+}
+
+// UpdateLiveOutputRequest is the body accepted when pausing or resuming an output
+type UpdateLiveOutputRequest struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
+
+// LiveOutput is a destination a live input is simulcast to
+type LiveOutput struct {
+	UID       string `json:"uid"`
+	URL       string `json:"url"`
+	StreamKey string `json:"streamKey"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// LiveOutputResponse represents Cloudflare's response for a single output
+type LiveOutputResponse struct {
+	Result   LiveOutput        `json:"result"`
+	Success  bool              `json:"success"`
+	Errors   []CloudflareError `json:"errors"`
+	Messages []string          `json:"messages"`
+}
+
+// LiveOutputListResponse represents Cloudflare's response when listing outputs
+type LiveOutputListResponse struct {
+	Result   []LiveOutput      `json:"result"`
+	Success  bool              `json:"success"`
+	Errors   []CloudflareError `json:"errors"`
+	Messages []string          `json:"messages"`
+}
+
+// validateOutputURL checks an output points at an RTMP(S) server
+func validateOutputURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("url is not a valid URL")
+	}
+	if !outputSchemes[u.Scheme] {
+		return fmt.Errorf("url scheme must be rtmp or rtmps, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("url has no host")
+	}
+	return nil
+}
+
+// redactOutput hides the stream key, which grants publishing rights on the destination
+func redactOutput(output LiveOutput) LiveOutput {
+	if output.StreamKey != "" {
+		output.StreamKey = redactedSecret
+	}
+	return output
+}
+
+func registerLiveOutputRoutes(app *fiber.App, config CloudflareConfig, timeout fiber.Handler) {
+	// Restream a live input to another RTMP(S) destination, e.g. YouTube or Twitch
+	app.Post("/api/live/:uid/outputs", timeout, requireJSON(), func(c *fiber.Ctx) error {
+		var body CreateLiveOutputRequest
+		if ok, err := bindBody(c, &body); !ok {
+			return err
+		}
+		if err := validateOutputURL(body.URL); err != nil {
+			return respondFieldErrors(c, []FieldError{{Field: "url", Error: err.Error()}})
+		}
+
+		payload := fiber.Map{
+			"url":       body.URL,
+			"streamKey": body.StreamKey,
+		}
+		if body.Enabled != nil {
+			payload["enabled"] = *body.Enabled
+		}
+
+		result, err := callCloudflare[LiveOutputResponse](c.UserContext(), config, "POST", "/stream/live_inputs/"+c.Params("uid")+"/outputs", payload)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to create output",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Output creation failed", result.Errors)
+		}
+
+		return respondResult(c, 201, redactOutput(result.Result), nil)
+	})
+
+	// List the outputs of a live input, with stream keys redacted
+	app.Get("/api/live/:uid/outputs", timeout, func(c *fiber.Ctx) error {
+		result, err := callCloudflare[LiveOutputListResponse](c.UserContext(), config, "GET", "/stream/live_inputs/"+c.Params("uid")+"/outputs", nil)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to list outputs",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Failed to list outputs", result.Errors)
+		}
+
+		outputs := make([]LiveOutput, 0, len(result.Result))
+		for _, output := range result.Result {
+			outputs = append(outputs, redactOutput(output))
+		}
+		return respondResult(c, 200, outputs, nil)
+	})
+
+	// Pause or resume restreaming to an output, keeping its URL and stream key
+	app.Post("/api/live/:uid/outputs/:outputId", timeout, requireJSON(), func(c *fiber.Ctx) error {
+		var body UpdateLiveOutputRequest
+		if ok, err := bindBody(c, &body); !ok {
+			return err
+		}
+
+		path := "/stream/live_inputs/" + c.Params("uid") + "/outputs/" + c.Params("outputId")
+		result, err := callCloudflare[LiveOutputResponse](c.UserContext(), config, "PUT", path, fiber.Map{
+			"enabled": *body.Enabled,
+		})
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to update output",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Output update failed", result.Errors)
+		}
+
+		return respondResult(c, 200, redactOutput(result.Result), nil)
+	})
+
+	// Stop restreaming to an output
+	app.Delete("/api/live/:uid/outputs/:outputId", timeout, func(c *fiber.Ctx) error {
+		status, err := deleteResource(c.UserContext(), config, "/stream/live_inputs/"+c.Params("uid")+"/outputs/"+c.Params("outputId"))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to delete output",
+				"details": err.Error(),
+			})
+		}
+		if status == 404 {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Output not found",
+			})
+		}
+		if status >= 300 {
+			return c.Status(502).JSON(fiber.Map{
+				"error":  "Cloudflare refused to delete the output",
+				"status": status,
+			})
+		}
+		return c.SendStatus(204)
+	})
+}
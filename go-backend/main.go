@@ -1,16 +1,22 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
-	"mime/multipart"
-	"net/http"
+	"log"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/joho/godotenv"
 )
 
@@ -19,6 +25,10 @@ type CloudflareConfig struct {
 	AccountID string
 	APIToken  string
 	BaseURL   string
+	// SigningKeyID and SigningKeyPEM select the Stream signing key used for
+	// playback tokens. Both are optional; without them Cloudflare signs tokens.
+	SigningKeyID  string
+	SigningKeyPEM string
 }
 
 // VideoStatus represents the status of a video
@@ -30,26 +40,97 @@ type VideoStatus struct {
 
 // CloudflareResult represents the result field in Cloudflare's response
 type CloudflareResult struct {
-	UID           string      `json:"uid"`
-	Preview       string      `json:"preview"`
-	Status        VideoStatus `json:"status"`
-	ReadyToStream bool        `json:"readyToStream"`
-	Thumbnail     string      `json:"thumbnail"`
-	Playback      struct {
-		HLS  string `json:"hls"`
-		Dash string `json:"dash"`
+	UID                string      `json:"uid"`
+	Preview            string      `json:"preview"`
+	Status             VideoStatus `json:"status"`
+	ReadyToStream      bool        `json:"readyToStream"`
+	Thumbnail          string      `json:"thumbnail"`
+	Created            time.Time   `json:"created"`
+	Modified           time.Time   `json:"modified"`
+	AllowedOrigins     []string    `json:"allowedOrigins"`
+	RequireSignedURLs  bool        `json:"requireSignedURLs"`
+	Creator            string      `json:"creator"`
+	MaxDurationSeconds int         `json:"maxDurationSeconds"`
+	Duration           float64     `json:"duration"`
+	Size               int64       `json:"size"`
+	Playback           struct {
+		HLS  string `json:"hls,omitempty"`
+		Dash string `json:"dash,omitempty"`
 	} `json:"playback"`
 	Meta struct {
-		Name string `json:"name"`
+		Name   string `json:"name"`
+		Labels string `json:"labels,omitempty"`
 	} `json:"meta"`
+	PublicDetails     PublicDetails `json:"publicDetails"`
+	ScheduledDeletion string        `json:"scheduledDeletion,omitempty"`
+	// LiveInput is the UID of the live input a recording came from; absent for VOD uploads
+	LiveInput string `json:"liveInput,omitempty"`
 }
 
 // VideoUploadResponse represents the complete response from Cloudflare
 type VideoUploadResponse struct {
-	Result   CloudflareResult `json:"result"`
-	Success  bool             `json:"success"`
-	Errors   interface{}      `json:"errors"`
-	Messages []string         `json:"messages"`
+	Result   CloudflareResult  `json:"result"`
+	Success  bool              `json:"success"`
+	Errors   []CloudflareError `json:"errors"`
+	Messages []string          `json:"messages"`
+
+	// Raw holds the original payload so fields we don't model yet stay reachable
+	Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes the result, parsing created and modified as RFC3339.
+// A timestamp that doesn't parse is logged and left as the zero time rather than
+// failing the whole response.
+func (r *CloudflareResult) UnmarshalJSON(data []byte) error {
+	type plain CloudflareResult
+	decoded := struct {
+		*plain
+		Created  string `json:"created"`
+		Modified string `json:"modified"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	r.Created = parseTimestamp(r.UID, "created", decoded.Created)
+	r.Modified = parseTimestamp(r.UID, "modified", decoded.Modified)
+	return nil
+}
+
+// parseTimestamp parses one of a video's RFC3339 timestamps, returning the zero
+// time (and logging) when Cloudflare sent something unexpected
+func parseTimestamp(uid, field, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		fmt.Printf("Could not parse %s %q of video %s: %v\n", field, value, uid, err)
+		return time.Time{}
+	}
+	return t
+}
+
+// formatTimestamp renders a timestamp for Cloudflare query parameters and exports,
+// or "" when it is unknown
+func formatTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// UnmarshalJSON decodes the modeled fields and keeps a copy of the full payload in Raw
+func (r *VideoUploadResponse) UnmarshalJSON(data []byte) error {
+	type plain VideoUploadResponse
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*r = VideoUploadResponse(decoded)
+	r.Raw = append(json.RawMessage(nil), data...)
+	return nil
 }
 
 func main() {
@@ -58,27 +139,260 @@ func main() {
 		fmt.Println("Error loading .env file")
 	}
 
-	// Initialize configuration
+	// Initialize configuration, picking the API host for this ENVIRONMENT
+	baseURL, err := resolveBaseURL()
+	if err != nil {
+		fmt.Printf("Invalid Cloudflare base URL: %v\n", err)
+		os.Exit(1)
+	}
 	config := CloudflareConfig{
-		AccountID: os.Getenv("CLOUDFLARE_ACCOUNT_ID"),
-		APIToken:  os.Getenv("CLOUDFLARE_API_TOKEN"),
-		BaseURL:   os.Getenv("CLOUDFLARE_BASE_URL"),
+		AccountID:     os.Getenv("CLOUDFLARE_ACCOUNT_ID"),
+		APIToken:      os.Getenv("CLOUDFLARE_API_TOKEN"),
+		BaseURL:       baseURL,
+		SigningKeyID:  os.Getenv("CLOUDFLARE_SIGNING_KEY_ID"),
+		SigningKeyPEM: os.Getenv("CLOUDFLARE_SIGNING_KEY_PEM"),
+	}
+	if err := validateSigningKey(config); err != nil {
+		fmt.Printf("Invalid signing key configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Optional TLS termination for deployments without a reverse proxy
+	tlsFiles, err := loadTLSFiles()
+	if err != nil {
+		fmt.Printf("Invalid TLS configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Raw or {data, meta} responses, the same for every endpoint
+	responseEnvelope, err = loadResponseEnvelope()
+	if err != nil {
+		fmt.Printf("Invalid response envelope: %v\n", err)
+		os.Exit(1)
+	}
+
+	// camelCase or snake_case video fields
+	jsonFieldCase, err = loadJSONFieldCase()
+	if err != nil {
+		fmt.Printf("Invalid JSON field case: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Read-only or fully disabled API during incidents and migrations
+	maintenanceMode, err := loadMaintenanceMode()
+	if err != nil {
+		fmt.Printf("Invalid maintenance mode: %v\n", err)
+		os.Exit(1)
+	}
+	if maintenanceMode != maintenanceOff {
+		fmt.Printf("Maintenance mode %s: requests it doesn't allow get a 503\n", maintenanceMode)
+	}
+
+	// Default access rules for issued playback tokens
+	tokenAccessRules, err := loadTokenAccessRules()
+	if err != nil {
+		fmt.Printf("Invalid token access rules: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Optional OpenTelemetry tracing, exported over OTLP
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		fmt.Printf("Invalid tracing configuration: %v\n", err)
+		os.Exit(1)
+	}
+	if shutdownTracing != nil {
+		// Flush buffered spans once the server has stopped
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			shutdownTracing(ctx)
+		}()
 	}
 
+	// Shared, pooled client for all outbound calls, guarded by a circuit breaker
+	breaker := newCircuitBreaker(envInt("BREAKER_FAILURE_THRESHOLD", 5), envDuration("BREAKER_COOLDOWN", 30*time.Second))
+	httpClient = newHTTPClient(HTTPClientConfig{
+		MaxIdleConns:        envInt("HTTP_MAX_IDLE_CONNS", 100),
+		MaxIdleConnsPerHost: envInt("HTTP_MAX_IDLE_CONNS_PER_HOST", 32),
+		IdleConnTimeout:     envDuration("HTTP_IDLE_CONN_TIMEOUT", 90*time.Second),
+		Breaker:             breaker,
+	})
+
+	// Map Cloudflare error codes to HTTP statuses, with optional overrides
+	if err := loadErrorStatusOverrides(os.Getenv("CLOUDFLARE_ERROR_STATUS_MAP")); err != nil {
+		fmt.Printf("Invalid CLOUDFLARE_ERROR_STATUS_MAP: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Optionally prove credentials and connectivity before accepting traffic
+	if envBool("STARTUP_CHECK", false) {
+		if err := startupCheck(config, envDuration("STARTUP_CHECK_TIMEOUT", 5*time.Second)); err != nil {
+			fmt.Printf("Startup check failed: %v\n", err)
+			if envBool("STARTUP_CHECK_FATAL", false) {
+				os.Exit(1)
+			}
+		} else {
+			fmt.Println("Startup check passed: Cloudflare reachable and credentials accepted")
+		}
+	}
+
+	// Fiber reads the whole request into memory before any handler runs, so
+	// UPLOAD_MAX_MB caps both the largest video upload and the memory one
+	// request can take. Bodies over it are refused with 413. Other routes hold
+	// their bodies to tighter limits: requireJSON to Fiber's default and caption
+	// uploads to CAPTION_MAX_MB.
+	uploadMaxBytes := envInt("UPLOAD_MAX_MB", 200) << 20
+	captionMaxBytes := int64(envInt("CAPTION_MAX_MB", 2)) << 20
+
 	// Create new Fiber app
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		BodyLimit: max(fiber.DefaultBodyLimit, uploadMaxBytes),
+	})
+
+	// Structured access logs, sampling routine successful GETs such as status polls
+	app.Use(accessLog(envInt("LOG_SAMPLE_RATE", 1)))
+
+	// A span per request, continuing any trace the caller started
+	app.Use(traceRequests())
+
+	// Enable CORS, with separate origin lists for uploads and for media embedded elsewhere
+	app.Use(corsByRouteGroup(loadCORSOrigins()))
+
+	// MAINTENANCE_MODE rejects writes (readonly) or everything but health checks (full)
+	app.Use(requireNoMaintenance(maintenanceMode, envDuration("MAINTENANCE_RETRY_AFTER", 5*time.Minute), os.Getenv("MAINTENANCE_MESSAGE")))
+
+	// Health of each account's API token, checked in the background below
+	credentialHealth := newCredentialHealth(config.AccountID)
+
+	// Bounded upload concurrency; keys in UPLOAD_PRIORITY_API_KEYS are served first
+	uploadSlots := newPrioritySemaphore(envInt("UPLOAD_CONCURRENCY", 4))
+	priorityKeys := envSet("UPLOAD_PRIORITY_API_KEYS")
+
+	// Optional signed notification to UPLOAD_CALLBACK_URL after each successful upload
+	var uploadCallback *UploadCallback
+	if callbackURL := os.Getenv("UPLOAD_CALLBACK_URL"); callbackURL != "" {
+		secret := os.Getenv("UPLOAD_CALLBACK_SECRET")
+		if secret == "" {
+			fmt.Println("Warning: UPLOAD_CALLBACK_SECRET not set, upload callbacks will not be signed")
+		}
+		uploadCallback = newUploadCallback(UploadCallbackConfig{
+			URL:         callbackURL,
+			Secret:      secret,
+			MaxAttempts: envInt("UPLOAD_CALLBACK_MAX_ATTEMPTS", 5),
+			RetryDelay:  envDuration("UPLOAD_CALLBACK_RETRY_DELAY", 2*time.Second),
+			Timeout:     envDuration("UPLOAD_CALLBACK_TIMEOUT", 10*time.Second),
+		})
+	}
 
-	// Enable CORS
-	app.Use(cors.New(cors.Config{
-		AllowOrigins: "http://localhost:5173", // Vite default port
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-		AllowMethods: "GET, POST",
-	}))
+	// Optional upload queue that rides out Cloudflare outages; UPLOAD_QUEUE_SIZE=0 disables it.
+	// ?mode=sync|queued|auto picks per request, defaulting to UPLOAD_MODE.
+	var uploadQueue *UploadQueue
+	if size := envInt("UPLOAD_QUEUE_SIZE", 0); size > 0 {
+		uploadQueue = newUploadQueue(UploadQueueConfig{
+			Size:        size,
+			Workers:     envInt("UPLOAD_QUEUE_WORKERS", 2),
+			MaxAttempts: envInt("UPLOAD_QUEUE_MAX_ATTEMPTS", 5),
+			RetryDelay:  envDuration("UPLOAD_QUEUE_RETRY_DELAY", 10*time.Second),
+			Retention:   envDuration("UPLOAD_JOB_RETENTION", time.Hour),
+		}, breaker, uploadSlots, uploadCallback)
+	}
+	uploadMode := os.Getenv("UPLOAD_MODE")
+	if uploadMode == "" {
+		uploadMode = uploadModeSync
+	}
+
+	// Fail fast during Cloudflare outages instead of piling up doomed requests
+	app.Use("/api", requireClosedCircuit(breaker, bypassesCircuit(uploadQueue, uploadMode, breaker)))
+	registerReadinessRoutes(app, breaker, credentialHealth)
+
+	// Every configured account by ID, for background work on stored records
+	accountsByID := map[string]CloudflareConfig{config.AccountID: config}
+
+	// Multi-tenant deployments pick the Cloudflare account from a signed caller JWT
+	if keyFile := os.Getenv("TENANT_JWT_PUBLIC_KEY_FILE"); keyFile != "" {
+		key, err := loadPublicKey(keyFile)
+		if err != nil {
+			fmt.Printf("Could not load TENANT_JWT_PUBLIC_KEY_FILE: %v\n", err)
+			os.Exit(1)
+		}
+		accounts, err := loadTenantAccounts(config)
+		if err != nil {
+			fmt.Printf("Invalid tenant account configuration: %v\n", err)
+			os.Exit(1)
+		}
+		for _, account := range accounts {
+			accountsByID[account.AccountID] = account
+		}
+		app.Use("/api", selectTenantAccount(key, accounts))
+	}
+
+	// Reject requests early while the selected account's API token is known to be revoked
+	app.Use("/api", requireHealthyCredentials(credentialHealth, config))
+
+	// Experimental areas can be switched off with FEATURE_<NAME>=false
+	app.Use("/api/live", requireFeature("live", true))
+	app.Use("/api/account/usage", requireFeature("analytics", true))
+
+	// Per-route deadlines: uploads stream whole files, everything else is a quick API call
+	uploadTimeout := withTimeout(envDuration("UPLOAD_TIMEOUT", 10*time.Minute))
+	apiTimeout := withTimeout(envDuration("API_TIMEOUT", 15*time.Second))
+
+	// Backend-side video records and the URL clients reach this server on
+	var videoStore VideoStore = newMemoryVideoStore()
+	publicBaseURL := os.Getenv("PUBLIC_BASE_URL")
+	if publicBaseURL == "" {
+		publicBaseURL = "http://localhost:3000"
+	}
+
+	// Optional content-hash deduplication of uploads
+	var contentIndex ContentIndex
+	if envBool("UPLOAD_DEDUP", false) {
+		contentIndex = newMemoryContentIndex()
+	}
+
+	// Allowed upload formats, globally and per API key
+	uploadTypes, err := loadUploadTypePolicy(os.Getenv("UPLOAD_ALLOWED_TYPES"), os.Getenv("UPLOAD_ALLOWED_TYPES_BY_KEY"))
+	if err != nil {
+		fmt.Printf("Invalid UPLOAD_ALLOWED_TYPES_BY_KEY: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Allowed upload extensions and how they combine with the MIME check
+	uploadExtensions := defaultUploadExtensions
+	if exts := splitExtensions(os.Getenv("ALLOWED_EXTENSIONS")); len(exts) > 0 {
+		uploadExtensions = exts
+	}
+	validationMode, err := loadValidationMode()
+	if err != nil {
+		fmt.Printf("Invalid upload validation: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Reject files whose header isn't a known video container (MP4, WebM/MKV, AVI, ...)
+	verifyContainers := envBool("UPLOAD_VERIFY_CONTAINER", false)
+
+	// Files over UPLOAD_SPOOL_THRESHOLD_MB are copied to a private temp file and
+	// uploaded from disk. That costs disk space and a local copy before the
+	// transfer starts, but a failed transfer is retried from disk. The client
+	// doesn't have to send the file again. Smaller files are sent once from the
+	// request body, which Fiber already holds in memory, so spooling trades disk
+	// for retries rather than saving memory. 0, the default, never spools.
+	spoolThreshold := int64(envInt("UPLOAD_SPOOL_THRESHOLD_MB", 0)) << 20
+	spoolAttempts := envInt("UPLOAD_SPOOL_ATTEMPTS", 3)
+
+	// Whether new videos require signed URLs unless a request says otherwise
+	requireSignedDefault := envBool("REQUIRE_SIGNED_URLS", false)
+	if requireSignedDefault && !signingConfigured(config) {
+		fmt.Println("Warning: REQUIRE_SIGNED_URLS is set but no signing key is configured, private videos depend on Cloudflare's token API")
+	}
 
 	// Upload endpoint
-	app.Post("/api/upload", func(c *fiber.Ctx) error {
-		fmt.Printf("Using Account ID: %s\n", config.AccountID)
-		fmt.Printf("Base URL: %s\n", config.BaseURL)
+	app.Post("/api/upload", uploadTimeout, rejectEmptyUpload("video"), func(c *fiber.Ctx) error {
+		start := time.Now()
+		account := accountConfig(c.UserContext(), config)
+		fmt.Printf("Using Account ID: %s\n", account.AccountID)
+		fmt.Printf("Base URL: %s\n", account.BaseURL)
 
 		// Get file from request
 		file, err := c.FormFile("video")
@@ -92,139 +406,551 @@ func main() {
 
 		fmt.Printf("Received file: %s, size: %d\n", file.Filename, file.Size)
 
-		// Open the file
-		fileContent, err := file.Open()
-		if err != nil {
-			fmt.Printf("File open error: %v\n", err)
-			return c.Status(500).JSON(fiber.Map{
-				"error":   "Could not open file",
-				"details": err.Error(),
-			})
+		allowedTypes := uploadTypes.Allowed(c.Get("X-API-Key"))
+		mediaType := uploadMediaType(file)
+		extension := uploadExtension(file)
+		typeOK := allowedTypes == nil || typeAllowed(mediaType, allowedTypes)
+		extensionOK := typeAllowed(extension, uploadExtensions)
+
+		switch validationMode {
+		case validateMIME:
+			extensionOK = true
+		case validateExtension:
+			typeOK = true
+		case validateEither:
+			// Either check passing is enough; when both fail, name the extension
+			extensionOK = typeOK || extensionOK
+			typeOK = true
 		}
-		defer fileContent.Close()
-
-		// Create multipart form data
-		body := &bytes.Buffer{}
-		writer := multipart.NewWriter(body)
-		part, err := writer.CreateFormFile("file", file.Filename)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error":   "Could not create form file",
-				"details": err.Error(),
+		if !typeOK {
+			return c.Status(415).JSON(fiber.Map{
+				"error":   "Unsupported video type",
+				"type":    mediaType,
+				"allowed": allowedTypes,
 			})
 		}
-
-		// Copy file content to form
-		if _, err := io.Copy(part, fileContent); err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error":   "Could not copy file content",
-				"details": err.Error(),
+		if !extensionOK {
+			return c.Status(415).JSON(fiber.Map{
+				"error":     fmt.Sprintf("Unsupported file extension %q", extension),
+				"extension": extension,
+				"allowed":   uploadExtensions,
 			})
 		}
-		writer.Close()
 
-		// Create Cloudflare Stream upload request
-		url := fmt.Sprintf("%s/accounts/%s/stream", config.BaseURL, config.AccountID)
-		fmt.Printf("Making request to: %s\n", url)
+		// Optionally check the bytes really are a video container, whatever the declared type
+		if verifyContainers {
+			container, err := sniffContainer(file)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Could not read file",
+					"details": err.Error(),
+				})
+			}
+			if container == "" {
+				return c.Status(415).JSON(fiber.Map{
+					"error": "File is not a recognised video container",
+					"type":  uploadMediaType(file),
+				})
+			}
+		}
 
-		req, err := http.NewRequest("POST", url, body)
+		var signedOverride *bool
+		if value := c.FormValue("requireSignedURLs"); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{
+					"error":   "Invalid requireSignedURLs",
+					"details": err.Error(),
+				})
+			}
+			signedOverride = &parsed
+		}
+		// Labels may be repeated form fields, comma-separated, or both
+		var labelValues []string
+		if form, err := c.MultipartForm(); err == nil {
+			labelValues = form.Value["labels"]
+		}
+		labels, err := parseLabels(labelValues)
 		if err != nil {
-			fmt.Printf("Request creation error: %v\n", err)
-			return c.Status(500).JSON(fiber.Map{
-				"error":   "Could not create request",
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid labels",
 				"details": err.Error(),
 			})
 		}
 
-		// Set headers
-		req.Header.Set("Authorization", "Bearer "+config.APIToken)
-		req.Header.Set("Content-Type", writer.FormDataContentType())
+		opts := UploadOptions{
+			Priority:      "normal",
+			RequireSigned: resolveRequireSignedURLs(signedOverride, requireSignedDefault),
+			Labels:        labels,
+		}
+		if priorityKeys[c.Get("X-API-Key")] {
+			opts.Priority = "high"
+		}
 
-		// Send request to Cloudflare
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			fmt.Printf("Cloudflare request error: %v\n", err)
-			return c.Status(500).JSON(fiber.Map{
-				"error":   "Failed to upload to Cloudflare",
-				"details": err.Error(),
+		// Queued uploads answer 202 with a job to poll; a full queue answers 429
+		mode := c.Query("mode", uploadMode)
+		if !uploadModes[mode] {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "mode must be one of sync, queued, auto",
 			})
 		}
-		defer resp.Body.Close()
+		if resolveUploadMode(mode, breaker) == uploadModeQueued {
+			if uploadQueue == nil {
+				return c.Status(400).JSON(fiber.Map{
+					"error": "Upload queue is disabled",
+				})
+			}
+			job, err := uploadQueue.Enqueue(file, account, opts)
+			if errors.Is(err, errQueueFull) {
+				return respondQueueFull(c, uploadQueue.cfg.RetryDelay)
+			}
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Could not queue upload",
+					"details": err.Error(),
+				})
+			}
+			c.Set("Location", "/api/jobs/"+job.ID)
+			return respond(c, 202, job)
+		}
 
-		// Read response body
-		bodyBytes, err := io.ReadAll(resp.Body)
+		// Open the file
+		fileContent, err := file.Open()
 		if err != nil {
-			fmt.Printf("Error reading response body: %v\n", err)
+			fmt.Printf("File open error: %v\n", err)
 			return c.Status(500).JSON(fiber.Map{
-				"error":   "Could not read response",
+				"error":   "Could not open file",
 				"details": err.Error(),
 			})
 		}
+		defer fileContent.Close()
 
-		fmt.Printf("Cloudflare Response Status: %d\n", resp.StatusCode)
-		fmt.Printf("Cloudflare Response Body: %s\n", string(bodyBytes))
+		// Hash the file as it is sent, to recognise content uploaded before
+		var src io.Reader = fileContent
+		var hasher hash.Hash
+		if contentIndex != nil {
+			hasher = sha256.New()
+			src = io.TeeReader(fileContent, hasher)
+		}
 
-		// Parse response
-		var result VideoUploadResponse
-		if err := json.Unmarshal(bodyBytes, &result); err != nil {
-			fmt.Printf("JSON parse error: %v\n", err)
-			return c.Status(500).JSON(fiber.Map{
-				"error":    "Could not parse response",
-				"details":  err.Error(),
-				"response": string(bodyBytes),
+		// Wait for an upload slot, ahead of normal uploads for priority keys
+		if err := uploadSlots.Acquire(c.UserContext(), opts.Priority == "high"); err != nil {
+			return c.Status(503).JSON(fiber.Map{
+				"error":   "Timed out waiting for an upload slot",
+				"details": err.Error(),
 			})
 		}
+		defer uploadSlots.Release()
+
+		// Time the transfer to Cloudflare (plus any spooling and retries) separately
+		// from the handler as a whole
+		var result VideoUploadResponse
+		var bodyBytes []byte
+		var streamedBytes int64
+		uploadStart := time.Now()
+		var uploadTook time.Duration
+		var uploadedUID string
+		defer func() {
+			if uploadTook == 0 {
+				uploadTook = time.Since(uploadStart)
+			}
+			recordUpload(UploadLogEntry{
+				Source:   "sync",
+				Filename: file.Filename,
+				UID:      uploadedUID,
+				Success:  c.Response().StatusCode() < 300,
+			}, UploadTiming{Bytes: streamedBytes, Upload: uploadTook, Total: time.Since(start)})
+		}()
+
+		if spoolThreshold > 0 && file.Size > spoolThreshold {
+			// Large files go to disk first so a failed attempt can be retried from there
+			var tee io.Writer
+			if hasher != nil {
+				tee = hasher
+			}
+			spooled, n, err := uploadFromSpool(c.UserContext(), config, file, spoolAttempts, tee)
+			streamedBytes = n
+			if err != nil {
+				fmt.Printf("Spooled upload error: %v\n", err)
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Failed to upload to Cloudflare",
+					"details": err.Error(),
+				})
+			}
+			result, bodyBytes = *spooled, spooled.Raw
+		} else {
+			// Stream the file to Cloudflare as multipart form data
+			streamedResult, raw, n, failure := streamUpload(c.UserContext(), config, src, file.Filename, file.Size)
+			streamedBytes = n
+			if failure != nil {
+				return respondUploadFailure(c, failure)
+			}
+			result, bodyBytes = *streamedResult, raw
+		}
+		uploadTook = time.Since(uploadStart)
+
+		uploadedUID = result.Result.UID
 
 		// Check if upload was successful
 		if !result.Success {
-			return c.Status(400).JSON(fiber.Map{
+			status := cloudflareErrorStatus(result.Errors)
+			if status == 429 {
+				return respondRateLimited(c, "Upload failed", result.Errors)
+			}
+			return c.Status(status).JSON(fiber.Map{
 				"error":    "Upload failed",
 				"details":  result.Errors,
 				"response": string(bodyBytes),
 			})
 		}
 
-		return c.JSON(result)
-	})
+		// A short body means Cloudflare stored a truncated file; remove it rather than keep a corrupt video
+		if mismatch := uploadSizeMismatch(file.Size, streamedBytes, result.Result.Size); mismatch != "" {
+			fmt.Printf("Incomplete upload of %s (%s), deleting %s\n", file.Filename, mismatch, result.Result.UID)
+			if _, err := deleteResource(c.UserContext(), config, "/stream/"+result.Result.UID); err != nil {
+				fmt.Printf("Could not delete incomplete video %s: %v\n", result.Result.UID, err)
+			}
+			return c.Status(502).JSON(fiber.Map{
+				"error":   "Upload was incomplete",
+				"details": mismatch,
+			})
+		}
 
-	// Get video status endpoint
-	app.Get("/api/video/:uid", func(c *fiber.Ctx) error {
-		uid := c.Params("uid")
-		url := fmt.Sprintf("%s/accounts/%s/stream/%s", config.BaseURL, config.AccountID, uid)
+		// Identical content uploaded before replaces this copy, as long as that video
+		// still exists and didn't fail processing
+		var contentKey string
+		if contentIndex != nil {
+			contentKey = contentIndexKey(accountConfig(c.UserContext(), config).AccountID, hex.EncodeToString(hasher.Sum(nil)))
+			if existing, ok := lookupDuplicate(c.UserContext(), config, contentIndex, contentKey); ok && existing.Result.UID != result.Result.UID {
+				uid := existing.Result.UID
+				fmt.Printf("Duplicate upload of %s, deleting %s and returning existing video %s\n", file.Filename, result.Result.UID, uid)
+				if _, err := deleteResource(c.UserContext(), config, "/stream/"+result.Result.UID); err != nil {
+					fmt.Printf("Could not delete duplicate video %s: %v\n", result.Result.UID, err)
+				}
+				// The existing video must end up as this upload asked, e.g. private
+				if !uploadOptionsMatch(existing, opts) {
+					if err := finalizeUpload(c.UserContext(), config, existing, opts); err != nil {
+						fmt.Printf("Could not apply upload options to existing video %s: %v\n", uid, err)
+						return c.Status(502).JSON(fiber.Map{
+							"error":   "Duplicate upload could not be given the requested settings",
+							"uid":     uid,
+							"details": err.Error(),
+						})
+					}
+				}
+				return respondResult(c, 200, existing.Result, cloudflareMeta(existing.Success, existing.Errors, existing.Messages))
+			}
+		}
+
+		// Tag the video with its priority and labels and apply the signing choice
+		if err := finalizeUpload(c.UserContext(), config, &result, opts); err != nil {
+			fmt.Printf("Could not update %s with priority %s and requireSignedURLs %t: %v\n", result.Result.UID, opts.Priority, opts.RequireSigned, err)
+			// Never report success for a video that should be private but is still public
+			if opts.RequireSigned {
+				return c.Status(502).JSON(fiber.Map{
+					"error": "Video uploaded but could not be made private",
+					"uid":   result.Result.UID,
+				})
+			}
+		}
 
-		req, err := http.NewRequest("GET", url, nil)
+		if contentIndex != nil {
+			contentIndex.Store(contentKey, result.Result.UID)
+		}
+		uploadCallback.Notify(&result, "sync", file.Filename)
+
+		return respondResult(c, 200, result.Result, cloudflareMeta(result.Success, result.Errors, result.Messages))
+	})
+
+	// Short-lived cache for status polls; ?fresh=true bypasses it
+	statusCache := newStatusCache(envDuration("STATUS_CACHE_TTL", 2*time.Second), envInt("STATUS_CACHE_SIZE", 1000))
+	// Signed URLs for private videos are reused for half the token lifetime
+	signedURLs := newSignedURLCache(signedAssetTTL/2, envInt("STATUS_CACHE_SIZE", 1000))
+
+	// Proxied thumbnails, optionally fetched ahead of time once a video is ready
+	thumbnailCache := newThumbnailCache(envDuration("THUMBNAIL_CACHE_TTL", 5*time.Minute), envInt("THUMBNAIL_CACHE_SIZE", 200))
+	var thumbnailPrewarmer *ThumbnailPrewarmer
+	if envBool("THUMBNAIL_PREWARM", false) {
+		spec := os.Getenv("THUMBNAIL_PREWARM_TIMES")
+		if spec == "" {
+			spec = defaultPrewarmTimes
+		}
+		times, err := parsePrewarmTimes(spec)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error":   "Could not create request",
-				"details": err.Error(),
+			fmt.Printf("Invalid THUMBNAIL_PREWARM_TIMES: %v\n", err)
+			os.Exit(1)
+		}
+		thumbnailPrewarmer = newThumbnailPrewarmer(config, thumbnailCache, times)
+	}
+
+	// Per-video version numbers for ?since delta polling
+	statusVersions := newStatusVersions(envInt("STATUS_CACHE_SIZE", 1000))
+
+	// Get video status endpoint. Every successful response carries its version as
+	// an ETag; with ?since=<version> only the fields changed since then are sent,
+	// or 304 when nothing has. Signed playback URLs are only resent when the
+	// version moves, so clients renew them with a request without since.
+	app.Get("/api/video/:uid", apiTimeout, func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+		playback := c.Query("playback", "both")
+		if !playbackPreferences[playback] {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "playback must be one of hls, dash, both",
 			})
 		}
 
-		req.Header.Set("Authorization", "Bearer "+config.APIToken)
+		cacheKey := accountConfig(c.UserContext(), config).AccountID + "/" + uid
+
+		result, cached := statusCache.Get(cacheKey)
+		if !cached || c.QueryBool("fresh", false) {
+			var err error
+			result, err = fetchVideo(c.UserContext(), config, uid)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Failed to get video status",
+					"details": err.Error(),
+				})
+			}
+			if result.Success {
+				statusCache.Put(cacheKey, result)
+			}
+		}
+		if result.Success && thumbnailPrewarmer != nil {
+			thumbnailPrewarmer.Trigger(c.UserContext(), result.Result)
+		}
+
+		dto := newVideoDTO(result.Result)
+		if result.Success {
+			// Videos still encoding have no playback URLs yet; leave the bundle out
+			if urls, ok := signedURLs.Get(cacheKey); ok && result.Result.RequireSignedURLs {
+				dto.URLs = &urls
+			} else if urls, err := playbackURLs(c.UserContext(), config, result.Result); err == nil {
+				dto.URLs = &urls
+				if result.Result.RequireSignedURLs {
+					signedURLs.Put(cacheKey, urls)
+				}
+			} else if result.Result.RequireSignedURLs {
+				fmt.Printf("Could not sign playback URLs for %s: %v\n", uid, err)
+			}
+		}
+		applyCustomPoster(&dto, videoStore, publicBaseURL)
+		applyPlaybackPreference(&dto, playback)
+
+		meta := cloudflareMeta(result.Success, result.Errors, result.Messages)
+		if c.QueryBool("raw", false) && len(result.Raw) > 0 {
+			meta["raw"] = result.Raw
+		}
 
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		if !result.Success {
+			return respondResult(c, 200, dto, meta)
+		}
+		fields, err := statusFields(dto)
 		if err != nil {
 			return c.Status(500).JSON(fiber.Map{
-				"error":   "Failed to get video status",
+				"error":   "Could not encode video status",
 				"details": err.Error(),
 			})
 		}
-		defer resp.Body.Close()
+		version := statusVersions.Observe(cacheKey, result.Raw, fields)
+		c.Set("ETag", statusETag(version))
 
-		var result VideoUploadResponse
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error":   "Could not parse response",
-				"details": err.Error(),
+		since := c.Query("since")
+		if since == "" {
+			return respondResult(c, 200, dto, meta)
+		}
+		sinceVersion, ok := parseStatusVersion(since)
+		if !ok {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "since must be a version number or ETag from an earlier response",
+				"details": since,
 			})
 		}
+		if sinceVersion == version {
+			return c.SendStatus(304)
+		}
 
-		return c.JSON(result)
+		// Versions we no longer remember, or never issued, get the full status
+		previous, ok := statusVersions.Snapshot(cacheKey, sinceVersion)
+		if !ok {
+			return respondResult(c, 200, dto, meta)
+		}
+		changed, removed := diffStatusFields(previous, fields)
+		// Cloudflare may change fields we don't serve; the ETag still moves on
+		if len(changed) == 0 && len(removed) == 0 {
+			return c.SendStatus(304)
+		}
+		meta["delta"] = true
+		meta["since"] = sinceVersion
+		meta["removed"] = removed
+		return respondResult(c, 200, changed, meta)
 	})
 
-	// Start server
+	// Push updates from Cloudflare webhooks to SSE subscribers
+	hub := newVideoHub()
+	webhookEvents := newWebhookLog(envInt("WEBHOOK_EVENT_LOG_SIZE", 100))
+	registerWebhookRoutes(app, os.Getenv("CLOUDFLARE_WEBHOOK_SECRET"), hub, webhookEvents, videoStore, thumbnailPrewarmer, apiTimeout)
+	streamLimiter := newStreamLimiter(envInt("MAX_STREAMS_PER_CLIENT", 5))
+	registerEventRoutes(app, config, hub, streamLimiter)
+
+	// Long-poll alternative to SSE for clients that can't hold a stream open
+	registerWaitRoutes(app, config, WaitConfig{
+		PollInterval: envDuration("WAIT_POLL_INTERVAL", 2*time.Second),
+		MaxWait:      envDuration("WAIT_MAX_TIMEOUT", time.Minute),
+	})
+
+	// Direct creator uploads
+	registerDirectUploadRoutes(app, config, DirectUploadConfig{
+		TTL:                envDuration("DIRECT_UPLOAD_TTL", 30*time.Minute),
+		MaxDurationSeconds: envInt("DIRECT_UPLOAD_MAX_DURATION_SECONDS", 3600),
+	}, videoStore, apiTimeout)
+	registerUploadTrackingRoutes(app, config, videoStore, apiTimeout)
+
+	// Video listing
+	registerListRoutes(app, config, apiTimeout)
+
+	// Watermark profiles
+	registerWatermarkRoutes(app, config, uploadTimeout)
+
+	// Account usage
+	registerUsageRoutes(app, config, envDuration("USAGE_CACHE_TTL", 5*time.Minute), apiTimeout)
+
+	// Signed playback tokens, with TOKEN_ACCESS_RULES applied to each
+	registerTokenRoutes(app, config, envDuration("TOKEN_TTL", time.Hour), tokenAccessRules, apiTimeout)
+
+	// Manage the account's Stream signing keys
+	registerSigningKeyRoutes(app, config, apiTimeout)
+
+	// Batch status lookups
+	registerBatchRoutes(app, config, envInt("BATCH_STATUS_CONCURRENCY", 5), apiTimeout)
+
+	// Embedding origins
+	registerOriginRoutes(app, config, apiTimeout)
+
+	// Access posture audit: signed URLs, origins and access rules
+	registerAccessRuleRoutes(app, config, apiTimeout)
+
+	// Source file details as Cloudflare measured them
+	registerInputRoutes(app, config, apiTimeout)
+
+	// Scrubbing previews
+	registerStoryboardRoutes(app, config, apiTimeout)
+
+	// Resized thumbnail frames
+	registerThumbnailRoutes(app, config, thumbnailCache, apiTimeout)
+
+	// Resumable MP4 downloads through our own domain
+	registerMP4Routes(app, config, apiTimeout)
+
+	// HLS playback through our own domain
+	registerManifestRoutes(app, config, apiTimeout)
+
+	// Available qualities, read from the HLS master playlist
+	registerRenditionRoutes(app, config, apiTimeout)
+
+	// Creator attribution
+	registerCreatorRoutes(app, config, apiTimeout)
+
+	// Live inputs
+	liveRecordingMode := os.Getenv("LIVE_DEFAULT_RECORDING_MODE")
+	if liveRecordingMode == "" {
+		liveRecordingMode = "automatic"
+	}
+	if !recordingModes[liveRecordingMode] {
+		fmt.Printf("Invalid LIVE_DEFAULT_RECORDING_MODE %q, expected automatic or off\n", liveRecordingMode)
+		os.Exit(1)
+	}
+	registerLiveRoutes(app, config, liveRecordingMode, apiTimeout)
+
+	// Simulcast live inputs to other RTMP(S) destinations
+	registerLiveOutputRoutes(app, config, apiTimeout)
+
+	// Inventory export
+	registerExportRoutes(app, config, apiTimeout)
+
+	// Custom posters
+	registerPosterRoutes(app, videoStore, publicBaseURL, apiTimeout)
+
+	// Short, stable share links under /v/ that redirect to the player
+	registerShareRoutes(app, config, videoStore, publicBaseURL, apiTimeout)
+
+	// Player branding
+	registerPublicDetailsRoutes(app, config, apiTimeout)
+	playerConfigs := newPlayerConfigStore()
+	registerPlayerConfigRoutes(app, config, playerConfigs, apiTimeout)
+
+	// Player page for serving embeds from our own domain; PLAYER_FRAME_ANCESTORS
+	// limits which sites may frame it
+	registerPlayerRoutes(app, config, playerConfigs, os.Getenv("PLAYER_FRAME_ANCESTORS"), apiTimeout)
+
+	// Free-form video meta
+	registerMetaRoutes(app, config, envInt("BULK_META_CONCURRENCY", 5), apiTimeout)
+
+	// Status of queued uploads
+	registerJobRoutes(app, uploadQueue, apiTimeout)
+
+	// Upload timing and throughput
+	registerMetricsRoutes(app)
+
+	// Clips cut from existing videos
+	registerClipRoutes(app, config, apiTimeout)
+
+	// Duplicates for A/B testing
+	registerDuplicateRoutes(app, config, requireSignedDefault, apiTimeout)
+
+	// Status, captions and downloads combined for detail pages
+	registerPackageRoutes(app, config, envInt("PACKAGE_CONCURRENCY", 3), apiTimeout)
+
+	// Caption tracks, with SRT converted to VTT, at most MAX_CAPTIONS_PER_VIDEO languages
+	// and files up to CAPTION_MAX_MB
+	registerCaptionRoutes(app, config, envInt("MAX_CAPTIONS_PER_VIDEO", 0), captionMaxBytes, apiTimeout)
+
+	// Cost estimates from a video's duration; defaults are Cloudflare's list prices in USD
+	registerEstimateRoutes(app, config, EstimateRates{
+		StoragePerMinute:  envFloat("ESTIMATE_STORAGE_RATE_PER_MINUTE", 0.005),
+		DeliveryPerMinute: envFloat("ESTIMATE_DELIVERY_RATE_PER_MINUTE", 0.001),
+	}, apiTimeout)
+
+	// TTL-with-keepalive via scheduledDeletion
+	registerRetentionRoutes(app, config, envDuration("SCHEDULED_DELETION_WINDOW", 30*24*time.Hour), apiTimeout)
+
+	// Stop background work and drain connections on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		app.Shutdown()
+	}()
+
+	// Recheck every account's API token until shutdown
+	go credentialHealth.Run(ctx, accountsByID, envDuration("HEALTH_CHECK_INTERVAL", time.Minute))
+
+	// Work through queued uploads
+	if uploadQueue != nil {
+		go uploadQueue.Run(ctx)
+	}
+
+	// Follow direct uploads to completion even when no webhook arrives
+	go runUploadReconciler(ctx, config, accountsByID, videoStore, envDuration("UPLOAD_RECONCILE_INTERVAL", time.Minute))
+
+	// Optional cleanup of failed and expired videos on demo accounts
+	if envBool("ENABLE_JANITOR", false) {
+		go runJanitor(ctx, config, JanitorConfig{
+			Interval: envDuration("JANITOR_INTERVAL", time.Hour),
+			ErrorAge: envDuration("JANITOR_ERROR_AGE", 24*time.Hour),
+		})
+	}
+
+	// Start server; Listen returns nil after a graceful shutdown and an error
+	// when the server could not start, e.g. the port is taken
+	if tlsFiles != nil {
+		fmt.Println("Server starting with TLS on port 3000...")
+		if err := app.ListenTLS(":3000", tlsFiles.CertFile, tlsFiles.KeyFile); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	fmt.Println("Server starting on port 3000...")
-	app.Listen(":3000")
+	if err := app.Listen(":3000"); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Maintenance modes for MAINTENANCE_MODE
+const (
+	maintenanceOff      = "off"
+	maintenanceReadOnly = "readonly"
+	maintenanceFull     = "full"
+)
+
+// readOnlyPosts are POST routes that only read, so they stay open in read-only mode:
+// batch status lookups and playback tokens for private videos
+var readOnlyPosts = regexp.MustCompile(`^/api/(videos/status|video/[^/]+/token)$`)
+
+// loadMaintenanceMode reads MAINTENANCE_MODE, defaulting to off
+func loadMaintenanceMode() (string, error) {
+	switch mode := os.Getenv("MAINTENANCE_MODE"); mode {
+	case "":
+		return maintenanceOff, nil
+	case maintenanceOff, maintenanceReadOnly, maintenanceFull:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("MAINTENANCE_MODE must be %s, %s or %s, got %q", maintenanceOff, maintenanceReadOnly, maintenanceFull, mode)
+	}
+}
+
+// allowedDuringMaintenance reports whether a request may proceed in the given mode.
+// Health checks always pass; read-only mode also lets reads through.
+func allowedDuringMaintenance(mode, method, path string) bool {
+	if mode == maintenanceOff || path == "/readyz" {
+		return true
+	}
+	if mode == maintenanceFull {
+		return false
+	}
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return true
+	case fiber.MethodPost:
+		return readOnlyPosts.MatchString(path)
+	default:
+		return false
+	}
+}
+
+// requireNoMaintenance rejects requests the maintenance mode doesn't allow with a
+// 503 and a Retry-After. message, when set, is shown to callers.
+func requireNoMaintenance(mode string, retryAfter time.Duration, message string) fiber.Handler {
+	if message == "" {
+		message = "The API is undergoing maintenance, try again later"
+		if mode == maintenanceReadOnly {
+			message = "The API is read-only during maintenance, try again later"
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		if allowedDuringMaintenance(mode, c.Method(), c.Path()) {
+			return c.Next()
+		}
+
+		c.Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		return c.Status(503).JSON(fiber.Map{
+			"error":       message,
+			"maintenance": mode,
+		})
+	}
+}
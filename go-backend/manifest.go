@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// hlsContentType is the media type players expect for HLS playlists
+const hlsContentType = "application/vnd.apple.mpegurl"
+
+// manifestURIAttribute matches URI="..." attributes on tags such as EXT-X-MEDIA and EXT-X-KEY
+var manifestURIAttribute = regexp.MustCompile(`URI="([^"]*)"`)
+
+// isStreamDeliveryHost reports whether host serves Cloudflare Stream media, so the
+// segment proxy can't be used to fetch arbitrary URLs
+func isStreamDeliveryHost(host string) bool {
+	return strings.HasSuffix(host, ".cloudflarestream.com") || host == "videodelivery.net"
+}
+
+// rewriteManifest resolves every URI in an HLS playlist against base and passes it
+// through rewrite, leaving all other lines untouched
+func rewriteManifest(manifest []byte, base *url.URL, rewrite func(*url.URL) string) []byte {
+	resolve := func(ref string) string {
+		u, err := base.Parse(ref)
+		if err != nil {
+			return ref
+		}
+		return rewrite(u)
+	}
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch trimmed := strings.TrimSpace(line); {
+		case trimmed == "":
+		case strings.HasPrefix(trimmed, "#"):
+			line = manifestURIAttribute.ReplaceAllStringFunc(line, func(attr string) string {
+				ref := manifestURIAttribute.FindStringSubmatch(attr)[1]
+				return `URI="` + resolve(ref) + `"`
+			})
+		default:
+			line = resolve(trimmed)
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// fetchDeliveryAsset GETs a playlist or segment from Cloudflare's delivery network
+func fetchDeliveryAsset(c *fiber.Ctx, assetURL string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(c.UserContext(), "GET", assetURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil, fmt.Errorf("Cloudflare returned %d", resp.StatusCode)
+	}
+
+	contents, err := io.ReadAll(resp.Body)
+	return resp, contents, err
+}
+
+func registerManifestRoutes(app *fiber.App, config CloudflareConfig, timeout fiber.Handler) {
+	// segmentProxyURL routes a delivery URL back through this server
+	segmentProxyURL := func(uid string, u *url.URL) string {
+		return "/api/video/" + url.PathEscape(uid) + "/hls?src=" + url.QueryEscape(u.String())
+	}
+
+	// HLS master playlist, with URIs made absolute and, for private videos,
+	// routed through the backend with the signing token already applied
+	app.Get("/api/video/:uid/manifest/video.m3u8", timeout, func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		video, err := fetchVideo(c.UserContext(), config, uid)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !video.Success {
+			return respondCloudflareError(c, "Failed to get video", video.Errors)
+		}
+
+		urls, err := playbackURLs(c.UserContext(), config, video.Result)
+		if err != nil {
+			return respondSigningError(c, 502, "Could not build manifest URL", err)
+		}
+		manifestURL, err := url.Parse(urls.HLS)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Invalid manifest URL",
+				"details": err.Error(),
+			})
+		}
+
+		_, manifest, err := fetchDeliveryAsset(c, urls.HLS)
+		if err != nil {
+			return c.Status(502).JSON(fiber.Map{
+				"error":   "Failed to fetch manifest",
+				"details": err.Error(),
+			})
+		}
+
+		rewrite := func(u *url.URL) string { return u.String() }
+		if video.Result.RequireSignedURLs {
+			rewrite = func(u *url.URL) string { return segmentProxyURL(uid, u) }
+		}
+
+		c.Set("Content-Type", hlsContentType)
+		c.Set("Cache-Control", "no-store")
+		return c.Send(rewriteManifest(manifest, manifestURL, rewrite))
+	})
+
+	// Variant playlists and segments referenced by a proxied manifest
+	app.Get("/api/video/:uid/hls", timeout, func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		src, err := url.Parse(c.Query("src"))
+		if err != nil || src.Scheme != "https" || !isStreamDeliveryHost(src.Host) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "src must be a Cloudflare Stream delivery URL",
+			})
+		}
+
+		resp, contents, err := fetchDeliveryAsset(c, src.String())
+		if err != nil {
+			return c.Status(502).JSON(fiber.Map{
+				"error":   "Failed to fetch media",
+				"details": err.Error(),
+			})
+		}
+
+		if strings.HasSuffix(src.Path, ".m3u8") {
+			c.Set("Content-Type", hlsContentType)
+			c.Set("Cache-Control", "no-store")
+			return c.Send(rewriteManifest(contents, src, func(u *url.URL) string {
+				return segmentProxyURL(uid, u)
+			}))
+		}
+
+		c.Set("Content-Type", resp.Header.Get("Content-Type"))
+		return c.Send(contents)
+	})
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxMetaKeys bounds how many meta keys a video may carry
+const maxMetaKeys = 20
+
+// videoMeta returns every meta field on a video, not just the ones we model
+func videoMeta(video *VideoUploadResponse) map[string]interface{} {
+	var raw struct {
+		Result struct {
+			Meta map[string]interface{} `json:"meta"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(video.Raw, &raw); err != nil || raw.Result.Meta == nil {
+		return map[string]interface{}{"name": video.Result.Meta.Name}
+	}
+	return raw.Result.Meta
+}
+
+// mergeMeta overlays updates on existing meta; a null value removes the key
+func mergeMeta(existing, updates map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(existing)+len(updates))
+	for key, value := range existing {
+		merged[key] = value
+	}
+	for key, value := range updates {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// BulkMetaRequest is the body accepted by the bulk meta endpoint
+type BulkMetaRequest struct {
+	UIDs []string               `json:"uids"`
+	Meta map[string]interface{} `json:"meta"`
+}
+
+// BulkMetaEntry holds either a video's updated meta or the error updating it
+type BulkMetaEntry struct {
+	Meta   map[string]interface{} `json:"meta,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+	Errors []CloudflareError      `json:"errors,omitempty"`
+}
+
+// updateMetaConcurrently applies the same meta update to each UID with at most
+// `concurrency` videos in flight, merging or replacing as the single-video endpoint does
+func updateMetaConcurrently(ctx context.Context, config CloudflareConfig, uids []string, updates map[string]interface{}, replace bool, concurrency int) map[string]BulkMetaEntry {
+	results := make(map[string]BulkMetaEntry, len(uids))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+
+	for _, uid := range uids {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(uid string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			entry := updateMetaEntry(ctx, config, uid, updates, replace)
+
+			mu.Lock()
+			results[uid] = entry
+			mu.Unlock()
+		}(uid)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// updateMetaEntry updates one video's meta for a bulk request
+func updateMetaEntry(ctx context.Context, config CloudflareConfig, uid string, updates map[string]interface{}, replace bool) BulkMetaEntry {
+	meta := updates
+	if !replace {
+		video, err := fetchVideo(ctx, config, uid)
+		if err != nil {
+			return BulkMetaEntry{Error: err.Error()}
+		}
+		if !video.Success {
+			return BulkMetaEntry{Error: "Failed to get video", Errors: video.Errors}
+		}
+		meta = mergeMeta(videoMeta(video), updates)
+	}
+
+	if len(meta) > maxMetaKeys {
+		return BulkMetaEntry{Error: fmt.Sprintf("meta must have at most %d entries", maxMetaKeys)}
+	}
+
+	result, err := updateVideo(ctx, config, uid, fiber.Map{
+		"uid":  uid,
+		"meta": meta,
+	})
+	if err != nil {
+		return BulkMetaEntry{Error: err.Error()}
+	}
+	if !result.Success {
+		return BulkMetaEntry{Error: "Failed to update meta", Errors: result.Errors}
+	}
+	return BulkMetaEntry{Meta: videoMeta(result)}
+}
+
+func registerMetaRoutes(app *fiber.App, config CloudflareConfig, concurrency int, timeout fiber.Handler) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// Every meta key on a video
+	app.Get("/api/video/:uid/meta", timeout, func(c *fiber.Ctx) error {
+		video, err := fetchVideo(c.UserContext(), config, c.Params("uid"))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !video.Success {
+			return respondCloudflareError(c, "Failed to get video", video.Errors)
+		}
+
+		return respond(c, 200, fiber.Map{
+			"meta": videoMeta(video),
+		})
+	})
+
+	// Update meta, merging into the existing keys unless ?replace=true. The legacy
+	// allowedCountries and blockedCountries fields are accepted alongside and map
+	// to the video's accessRules rather than to meta keys.
+	app.Post("/api/video/:uid/meta", timeout, requireJSON(), func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(c.Body(), &body); err != nil || body == nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Request body must be a JSON object of meta keys",
+			})
+		}
+
+		accessRules, hasCountries, fieldErrs := legacyCountryRules(body)
+		if len(fieldErrs) > 0 {
+			return c.Status(400).JSON(fiber.Map{
+				"error":  "Invalid legacy country fields",
+				"fields": fieldErrs,
+			})
+		}
+		payload := fiber.Map{"uid": uid}
+		if hasCountries {
+			payload["accessRules"] = accessRules
+		}
+
+		// A body of only legacy fields leaves meta as it is
+		meta := body
+		if hasCountries && len(body) == 0 {
+			meta = nil
+		} else if !c.QueryBool("replace", false) {
+			video, err := fetchVideo(c.UserContext(), config, uid)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Failed to get video",
+					"details": err.Error(),
+				})
+			}
+			if !video.Success {
+				return respondCloudflareError(c, "Failed to get video", video.Errors)
+			}
+			meta = mergeMeta(videoMeta(video), body)
+		}
+
+		if len(meta) > maxMetaKeys {
+			return respondFieldErrors(c, []FieldError{{Field: "meta", Error: "must have at most 20 entries"}})
+		}
+
+		if meta != nil {
+			payload["meta"] = meta
+		}
+		result, err := updateVideo(c.UserContext(), config, uid, payload)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to update video",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Failed to update meta", result.Errors)
+		}
+
+		response := fiber.Map{
+			"meta": videoMeta(result),
+		}
+		// Echo what Cloudflare kept rather than what was sent, so a caller can
+		// see whether the rules took
+		if hasCountries {
+			response["accessRules"] = normalizeAccessRules(storedAccessRules(result))
+		}
+		return respond(c, 200, response)
+	})
+	// Apply one meta update to many videos, merging unless ?replace=true
+	app.Post("/api/videos/meta", timeout, requireJSON(), func(c *fiber.Ctx) error {
+		var body BulkMetaRequest
+		if ok, err := bindBody(c, &body); !ok {
+			return err
+		}
+		if body.Meta == nil {
+			return respondFieldErrors(c, []FieldError{{Field: "meta", Error: "is required"}})
+		}
+
+		seen := make(map[string]bool, len(body.UIDs))
+		uids := make([]string, 0, len(body.UIDs))
+		for _, uid := range body.UIDs {
+			if uid != "" && !seen[uid] {
+				seen[uid] = true
+				uids = append(uids, uid)
+			}
+		}
+
+		if len(uids) == 0 {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "uids must contain at least one video UID",
+			})
+		}
+		if len(uids) > maxBatchUIDs {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Too many uids in one batch",
+				"limit": maxBatchUIDs,
+			})
+		}
+
+		replace := c.QueryBool("replace", false)
+		// A replacement is the same for every video, so an oversized one can be rejected up front
+		if replace && len(body.Meta) > maxMetaKeys {
+			return respondFieldErrors(c, []FieldError{{Field: "meta", Error: "must have at most 20 entries"}})
+		}
+
+		results := updateMetaConcurrently(c.UserContext(), config, uids, body.Meta, replace, concurrency)
+		failed := 0
+		for _, entry := range results {
+			if entry.Error != "" {
+				failed++
+			}
+		}
+		return respondResult(c, 200, results, fiber.Map{
+			"updated": len(results) - failed,
+			"failed":  failed,
+		})
+	})
+}
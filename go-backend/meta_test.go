@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMergeMeta(t *testing.T) {
+	existing := map[string]interface{}{"name": "clip", "team": "red", "stale": "yes"}
+	updates := map[string]interface{}{"team": "blue", "stale": nil, "added": "1"}
+
+	got := mergeMeta(existing, updates)
+	want := map[string]interface{}{"name": "clip", "team": "blue", "added": "1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeMeta = %v, want %v", got, want)
+	}
+	if existing["team"] != "red" || existing["stale"] != "yes" {
+		t.Errorf("mergeMeta modified existing meta: %v", existing)
+	}
+}
+
+// fakeMetaCloudflare serves a single video whose meta persists across updates,
+// counting the updates it receives
+type fakeMetaCloudflare struct {
+	mu      sync.Mutex
+	meta    map[string]interface{}
+	updates int
+}
+
+func (f *fakeMetaCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Method == "POST" {
+		var body struct {
+			Meta map[string]interface{} `json:"meta"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.meta = body.Meta
+		f.updates++
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"errors":   []interface{}{},
+		"messages": []interface{}{},
+		"result":   map[string]interface{}{"uid": "abc", "meta": f.meta},
+	})
+}
+
+func newMetaTestApp(t *testing.T, existing map[string]interface{}) (*fiber.App, *fakeMetaCloudflare) {
+	t.Helper()
+	fake := &fakeMetaCloudflare{meta: existing}
+	config := newFakeCloudflare(t, fake)
+
+	app := fiber.New()
+	registerMetaRoutes(app, config, 1, noTimeout)
+	return app, fake
+}
+
+func postMeta(t *testing.T, app *fiber.App, query, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/video/abc/meta"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("response %s is not JSON: %v", data, err)
+	}
+	return resp.StatusCode, decoded
+}
+
+func TestPostMetaMergesByDefault(t *testing.T) {
+	app, fake := newMetaTestApp(t, map[string]interface{}{"name": "clip", "team": "red"})
+
+	status, body := postMeta(t, app, "", `{"team":"blue","season":"2"}`)
+	if status != 200 {
+		t.Fatalf("status = %d, body %v", status, body)
+	}
+	want := map[string]interface{}{"name": "clip", "team": "blue", "season": "2"}
+	if !reflect.DeepEqual(fake.meta, want) {
+		t.Errorf("stored meta = %v, want %v", fake.meta, want)
+	}
+	if !reflect.DeepEqual(body["meta"], want) {
+		t.Errorf("response meta = %v, want %v", body["meta"], want)
+	}
+}
+
+func TestPostMetaReplace(t *testing.T) {
+	app, fake := newMetaTestApp(t, map[string]interface{}{"name": "clip", "team": "red"})
+
+	status, body := postMeta(t, app, "?replace=true", `{"season":"2"}`)
+	if status != 200 {
+		t.Fatalf("status = %d, body %v", status, body)
+	}
+	want := map[string]interface{}{"season": "2"}
+	if !reflect.DeepEqual(fake.meta, want) {
+		t.Errorf("stored meta = %v, want %v", fake.meta, want)
+	}
+}
+
+func TestPostMetaKeyLimitAppliesAfterMerge(t *testing.T) {
+	existing := make(map[string]interface{}, maxMetaKeys)
+	for i := 0; i < maxMetaKeys; i++ {
+		existing[fmt.Sprintf("key%d", i)] = "v"
+	}
+
+	// One new key on top of a full set is over the limit once merged
+	app, fake := newMetaTestApp(t, existing)
+	status, body := postMeta(t, app, "", `{"extra":"v"}`)
+	if status != 422 {
+		t.Errorf("status = %d, want 422; body %v", status, body)
+	}
+	if fake.updates != 0 {
+		t.Errorf("Cloudflare got %d updates for a rejected request", fake.updates)
+	}
+
+	// Removing a key in the same request keeps the merged meta within the limit
+	status, body = postMeta(t, app, "", `{"extra":"v","key0":null}`)
+	if status != 200 {
+		t.Errorf("status = %d, want 200; body %v", status, body)
+	}
+	if len(fake.meta) != maxMetaKeys {
+		t.Errorf("stored %d keys, want %d", len(fake.meta), maxMetaKeys)
+	}
+
+	// Replacing sends only the body, so the existing keys don't count
+	status, body = postMeta(t, app, "?replace=true", `{"extra":"v"}`)
+	if status != 200 {
+		t.Errorf("replace status = %d, want 200; body %v", status, body)
+	}
+}
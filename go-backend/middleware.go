@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// withTimeout gives the rest of the handler chain a deadline through the user
+// context. Outbound Cloudflare calls use that context, so they are cancelled
+// once it expires and the client gets a 504 instead of a generic failure.
+func withTimeout(d time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(c.UserContext(), d)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return c.Status(504).JSON(fiber.Map{
+				"error":   "Request timed out",
+				"details": fmt.Sprintf("handler exceeded its %s deadline", d),
+			})
+		}
+		return err
+	}
+}
+
+// clientKey identifies the caller for per-client limits: its API key when one
+// is sent, otherwise its IP address
+func clientKey(c *fiber.Ctx) string {
+	if key := c.Get("X-API-Key"); key != "" {
+		return "key:" + key
+	}
+	return "ip:" + c.IP()
+}
+
+// requireJSON rejects requests whose body isn't declared as JSON with a 415.
+// Fiber's body parser would otherwise accept form-encoded bodies and leave every
+// field zero. Requests without a body pass, for endpoints whose body is optional.
+// JSON bodies are held to Fiber's default limit even when the server-wide limit
+// is raised for video uploads.
+func requireJSON() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if len(c.Body()) > fiber.DefaultBodyLimit {
+			return c.Status(413).JSON(fiber.Map{
+				"error": "Request body is too large",
+				"limit": fiber.DefaultBodyLimit,
+			})
+		}
+		if len(c.Body()) > 0 && !c.Is("json") {
+			return c.Status(415).JSON(fiber.Map{
+				"error":   "Content-Type must be application/json",
+				"details": c.Get("Content-Type"),
+			})
+		}
+		return c.Next()
+	}
+}
+
+// limitBody answers 413 when the request body is over limit bytes, for routes
+// whose bodies should stay well under the server-wide limit set for videos
+func limitBody(limit int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if len(c.Body()) > limit {
+			return c.Status(413).JSON(fiber.Map{
+				"error": "Request body is too large",
+				"limit": limit,
+			})
+		}
+		return c.Next()
+	}
+}
+
+// rejectEmptyUpload answers 400 when the multipart file in field is empty, before
+// the upload handler sends anything to Cloudflare, which rejects empty bodies
+// with an unhelpful error. A missing file is left for the handler to report.
+func rejectEmptyUpload(field string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if file, err := c.FormFile(field); err == nil && file.Size == 0 {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Empty file",
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// uploadRequest builds a multipart upload with content as the video file
+func uploadRequest(t *testing.T, content []byte) *http.Request {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("video", "clip.mp4")
+	if err != nil {
+		t.Fatalf("CreateFormFile: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestRejectEmptyUpload(t *testing.T) {
+	var received atomic.Int64
+	config := newFakeCloudflare(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		io.Copy(io.Discard, r.Body)
+		io.WriteString(w, `{"success":true,"errors":[],"messages":[],"result":{"uid":"abc"}}`)
+	}))
+
+	app := fiber.New()
+	app.Post("/api/upload", rejectEmptyUpload("video"), func(c *fiber.Ctx) error {
+		file, err := c.FormFile("video")
+		if err != nil {
+			return c.SendStatus(400)
+		}
+		content, err := file.Open()
+		if err != nil {
+			return c.SendStatus(500)
+		}
+		defer content.Close()
+		if _, _, _, failure := streamUpload(c.UserContext(), config, content, file.Filename, file.Size); failure != nil {
+			return respondUploadFailure(c, failure)
+		}
+		return c.SendStatus(200)
+	})
+
+	resp, err := app.Test(uploadRequest(t, nil))
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	if resp.StatusCode != 400 {
+		t.Errorf("empty file: status = %d, want 400", resp.StatusCode)
+	}
+	if n := received.Load(); n != 0 {
+		t.Errorf("empty file: Cloudflare received %d requests, want none", n)
+	}
+
+	resp, err = app.Test(uploadRequest(t, []byte("not empty")))
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("non-empty file: status = %d, want 200", resp.StatusCode)
+	}
+	if n := received.Load(); n != 1 {
+		t.Errorf("non-empty file: Cloudflare received %d requests, want 1", n)
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	app := fiber.New()
+	app.Put("/captions", limitBody(8), func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{"12345678", 200},
+		{"123456789", 413},
+	} {
+		resp, err := app.Test(httptest.NewRequest("PUT", "/captions", bytes.NewBufferString(tc.body)))
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Errorf("%d-byte body: status = %d, want %d", len(tc.body), resp.StatusCode, tc.want)
+		}
+	}
+}
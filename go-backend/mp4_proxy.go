@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// mp4RelayedHeaders are copied from Cloudflare's download response so clients can
+// size, resume and validate the download
+var mp4RelayedHeaders = []string{
+	"Content-Type",
+	"Content-Range",
+	"Accept-Ranges",
+	"ETag",
+	"Last-Modified",
+}
+
+// mp4ContentLength asks Cloudflare for the download's size with a HEAD request,
+// returning -1 when it doesn't say
+func mp4ContentLength(ctx context.Context, mp4URL string) int64 {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", mp4URL, nil)
+	if err != nil {
+		return -1
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return -1
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return -1
+	}
+	return resp.ContentLength
+}
+
+func registerMP4Routes(app *fiber.App, config CloudflareConfig, timeout fiber.Handler) {
+	// Proxy a video's MP4 download with its real Content-Length and Range support,
+	// so download managers can show progress and resume. Registering GET also
+	// answers HEAD, which is relayed to Cloudflare as a HEAD.
+	// The body streams after the handler returns, so timeout only bounds the
+	// lookup before it; the download itself has no deadline.
+	app.Get("/api/video/:uid/mp4", timeout, func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		video, err := fetchVideo(c.UserContext(), config, uid)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !video.Success {
+			return respondCloudflareError(c, "Failed to get video", video.Errors)
+		}
+		urls, err := playbackURLs(c.UserContext(), config, video.Result)
+		if err != nil {
+			return respondSigningError(c, 502, "Could not build download URL", err)
+		}
+
+		// The download may outlive the handler, so keep the request's values but not its deadline
+		ctx := context.WithoutCancel(c.UserContext())
+		req, err := http.NewRequestWithContext(ctx, c.Method(), urls.MP4, nil)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not create request",
+				"details": err.Error(),
+			})
+		}
+		for _, header := range []string{"Range", "If-Range"} {
+			if value := c.Get(header); value != "" {
+				req.Header.Set(header, value)
+			}
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return c.Status(502).JSON(fiber.Map{
+				"error":   "Failed to fetch download",
+				"details": err.Error(),
+			})
+		}
+		switch resp.StatusCode {
+		case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		case http.StatusNotFound:
+			resp.Body.Close()
+			return c.Status(404).JSON(fiber.Map{
+				"error": "MP4 download not available, enable downloads for this video first",
+			})
+		default:
+			resp.Body.Close()
+			return c.Status(502).JSON(fiber.Map{
+				"error":  "Cloudflare returned an error for the download",
+				"status": resp.StatusCode,
+			})
+		}
+
+		// A chunked full download doesn't state its size; ask for it separately
+		// and stream without a length if Cloudflare still won't say
+		length := resp.ContentLength
+		if length < 0 && resp.StatusCode == http.StatusOK {
+			length = mp4ContentLength(c.UserContext(), urls.MP4)
+		}
+
+		for _, header := range mp4RelayedHeaders {
+			if value := resp.Header.Get(header); value != "" {
+				c.Set(header, value)
+			}
+		}
+		c.Set("Content-Disposition", `attachment; filename="`+uid+`.mp4"`)
+		c.Set("Cache-Control", "private, no-store")
+		c.Status(resp.StatusCode)
+
+		if c.Method() == fiber.MethodHead {
+			resp.Body.Close()
+			if length >= 0 {
+				c.Set("Content-Length", strconv.FormatInt(length, 10))
+			}
+			return nil
+		}
+		// fasthttp closes the body once it has been streamed
+		c.Context().SetBodyStream(resp.Body, int(length))
+		return nil
+	})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// hostnameLabel matches a single DNS label
+var hostnameLabel = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// AllowedOriginsRequest is the body accepted when setting a video's allowed origins
+type AllowedOriginsRequest struct {
+	AllowedOrigins []string `json:"allowedOrigins"`
+}
+
+// validateOrigin checks an entry is a hostname, optionally with a leading "*."
+// wildcard for subdomains, which is the only wildcard form Cloudflare accepts.
+func validateOrigin(origin string) error {
+	host := strings.TrimPrefix(origin, "*.")
+	if strings.Contains(host, "*") {
+		return errors.New("wildcards are only allowed as a leading \"*.\"")
+	}
+	if host == "" || len(host) > 253 {
+		return fmt.Errorf("%q is not a valid hostname", origin)
+	}
+
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 && host != "localhost" {
+		return fmt.Errorf("%q is not a valid hostname", origin)
+	}
+	for _, label := range labels {
+		if !hostnameLabel.MatchString(label) {
+			return fmt.Errorf("%q is not a valid hostname", origin)
+		}
+	}
+
+	return nil
+}
+
+// normalizeOrigin reduces an entry to the bare hostname form Cloudflare expects:
+// scheme, path and port are stripped and the host is lower-cased, so
+// "https://Example.com:8443/app" becomes "example.com". The result is validated.
+func normalizeOrigin(entry string) (string, error) {
+	host := strings.TrimSpace(entry)
+	if _, rest, ok := strings.Cut(host, "://"); ok {
+		host = rest
+	}
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	if err := validateOrigin(host); err != nil {
+		return "", fmt.Errorf("allowed origin %q: %w", entry, err)
+	}
+	return host, nil
+}
+
+// normalizeOrigins normalizes every entry, dropping duplicates
+func normalizeOrigins(entries []string) ([]string, error) {
+	normalized := make([]string, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		host, err := normalizeOrigin(entry)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[host] {
+			seen[host] = true
+			normalized = append(normalized, host)
+		}
+	}
+	return normalized, nil
+}
+
+func registerOriginRoutes(app *fiber.App, config CloudflareConfig, timeout fiber.Handler) {
+	// Origins allowed to embed the video; an empty list allows any origin
+	app.Get("/api/video/:uid/allowed-origins", timeout, func(c *fiber.Ctx) error {
+		result, err := fetchVideo(c.UserContext(), config, c.Params("uid"))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Failed to get video", result.Errors)
+		}
+
+		origins := result.Result.AllowedOrigins
+		if origins == nil {
+			origins = []string{}
+		}
+		return respond(c, 200, fiber.Map{
+			"allowedOrigins": origins,
+		})
+	})
+
+	app.Post("/api/video/:uid/allowed-origins", timeout, requireJSON(), func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		var body AllowedOriginsRequest
+		if ok, err := bindBody(c, &body); !ok {
+			return err
+		}
+
+		origins, err := normalizeOrigins(body.AllowedOrigins)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid allowed origin",
+				"details": err.Error(),
+			})
+		}
+
+		result, err := updateVideo(c.UserContext(), config, uid, fiber.Map{
+			"uid":            uid,
+			"allowedOrigins": origins,
+		})
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to update video",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Failed to update allowed origins", result.Errors)
+		}
+
+		return respond(c, 200, fiber.Map{
+			"allowedOrigins": result.Result.AllowedOrigins,
+		})
+	})
+}
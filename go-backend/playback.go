@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+)
+
+// signedAssetTTL is how long tokens minted for backend-fetched assets stay valid
+const signedAssetTTL = 10 * time.Minute
+
+// customerBaseURL returns the customer subdomain (e.g. https://customer-x.cloudflarestream.com)
+// serving this video, derived from the playback URLs Cloudflare reports.
+func customerBaseURL(result CloudflareResult) (string, error) {
+	for _, raw := range []string{result.Playback.HLS, result.Playback.Dash, result.Preview} {
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		return u.Scheme + "://" + u.Host, nil
+	}
+	return "", errors.New("video has no playback URLs yet")
+}
+
+// resolveRequireSignedURLs decides whether a new video is private: an explicit
+// per-request choice wins over the configured default
+func resolveRequireSignedURLs(override *bool, def bool) bool {
+	if override != nil {
+		return *override
+	}
+	return def
+}
+
+// playbackID returns the identifier used in delivery URLs: the UID for public
+// videos, or a short-lived signed token when the video requires signed URLs.
+func playbackID(ctx context.Context, config CloudflareConfig, result CloudflareResult) (string, error) {
+	if !result.RequireSignedURLs {
+		return result.UID, nil
+	}
+
+	token, err := createToken(ctx, config, result.UID, map[string]interface{}{
+		"exp": time.Now().Add(signedAssetTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	if !token.Success {
+		return "", &TokenError{Errors: token.Errors}
+	}
+	return token.Result.Token, nil
+}
+
+// PlaybackURLs is every delivery URL for a video, signed when the video requires it
+type PlaybackURLs struct {
+	HLS        string `json:"hls,omitempty"`
+	Dash       string `json:"dash,omitempty"`
+	Thumbnail  string `json:"thumbnail"`
+	Iframe     string `json:"iframe"`
+	MP4        string `json:"mp4"`
+	Storyboard string `json:"storyboard"`
+}
+
+// newPlaybackURLs builds the URL bundle for a delivery ID (a UID or a signed token)
+// on the given customer subdomain
+func newPlaybackURLs(base, id string) PlaybackURLs {
+	prefix := base + "/" + id
+	return PlaybackURLs{
+		HLS:        prefix + "/manifest/video.m3u8",
+		Dash:       prefix + "/manifest/video.mpd",
+		Thumbnail:  prefix + "/thumbnails/thumbnail.jpg",
+		Iframe:     prefix + "/iframe",
+		MP4:        prefix + "/downloads/default.mp4",
+		Storyboard: prefix + "/storyboard.json",
+	}
+}
+
+// playbackURLs resolves the customer subdomain and delivery ID for a video and
+// returns its URL bundle, minting a token first if the video requires signed URLs.
+func playbackURLs(ctx context.Context, config CloudflareConfig, result CloudflareResult) (PlaybackURLs, error) {
+	base, err := customerBaseURL(result)
+	if err != nil {
+		return PlaybackURLs{}, err
+	}
+	id, err := playbackID(ctx, config, result)
+	if err != nil {
+		return PlaybackURLs{}, err
+	}
+	return newPlaybackURLs(base, id), nil
+}
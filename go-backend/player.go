@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// playerStyle is the page's only stylesheet. Its hash is allowed by the CSP so
+// no inline style other than this one can run.
+const playerStyle = `html,body{margin:0;height:100%;background:#000}iframe{border:0;width:100%;height:100%}`
+
+// playerPage embeds the Stream player full-window
+var playerPage = template.Must(template.New("player").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>` + playerStyle + `</style>
+</head>
+<body>
+<iframe src="{{.Src}}" title="{{.Title}}" allow="accelerometer; gyroscope; autoplay; encrypted-media; picture-in-picture;" allowfullscreen></iframe>
+</body>
+</html>
+`))
+
+// playerStyleHash is the CSP source expression for playerStyle
+var playerStyleHash = func() string {
+	sum := sha256.Sum256([]byte(playerStyle))
+	return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+}()
+
+// playerCSP locks the page down to framing the player from the customer
+// subdomain. frameAncestors, when set, limits which sites may embed the page.
+func playerCSP(playerOrigin, frameAncestors string) string {
+	directives := []string{
+		"default-src 'none'",
+		"frame-src " + playerOrigin,
+		"style-src " + playerStyleHash,
+		"base-uri 'none'",
+		"form-action 'none'",
+	}
+	if frameAncestors != "" {
+		directives = append(directives, "frame-ancestors "+frameAncestors)
+	}
+	return strings.Join(directives, "; ")
+}
+
+// playerEmbedParams combines the account's player branding with per-request
+// options; query params win over the account defaults
+func playerEmbedParams(c *fiber.Ctx, defaults PlayerConfig) (url.Values, error) {
+	params := url.Values{}
+	if defaults.PrimaryColor != "" {
+		params.Set("primaryColor", defaults.PrimaryColor)
+	}
+	if defaults.LetterboxColor != "" {
+		params.Set("letterboxColor", defaults.LetterboxColor)
+	}
+
+	if color := c.Query("primaryColor"); color != "" {
+		if err := validate.Var(color, "hexcolor"); err != nil {
+			return nil, errors.New("primaryColor must be a hex color such as #f48120")
+		}
+		params.Set("primaryColor", color)
+	}
+	for _, flag := range []string{"autoplay", "loop", "muted"} {
+		if c.QueryBool(flag, false) {
+			params.Set(flag, "true")
+		}
+	}
+	return params, nil
+}
+
+func registerPlayerRoutes(app *fiber.App, config CloudflareConfig, players *playerConfigStore, frameAncestors string, timeout fiber.Handler) {
+	// An HTML page embedding the Stream player, so it can be served from our own
+	// domain. Private videos are embedded with a short-lived signed token.
+	app.Get("/api/video/:uid/player", timeout, func(c *fiber.Ctx) error {
+		account := accountConfig(c.UserContext(), config)
+		params, err := playerEmbedParams(c, players.Get(account.AccountID))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid player options",
+				"details": err.Error(),
+			})
+		}
+
+		video, err := fetchVideo(c.UserContext(), config, c.Params("uid"))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !video.Success {
+			return respondCloudflareError(c, "Failed to get video", video.Errors)
+		}
+
+		base, err := customerBaseURL(video.Result)
+		if err != nil {
+			return c.Status(502).JSON(fiber.Map{
+				"error":   "Could not determine playback domain",
+				"details": err.Error(),
+			})
+		}
+		urls, err := playbackURLs(c.UserContext(), config, video.Result)
+		if err != nil {
+			return respondSigningError(c, 502, "Could not build player URL", err)
+		}
+
+		src := urls.Iframe
+		if len(params) > 0 {
+			src += "?" + params.Encode()
+		}
+		title := video.Result.Meta.Name
+		if title == "" {
+			title = "Video"
+		}
+
+		var page bytes.Buffer
+		if err := playerPage.Execute(&page, struct{ Title, Src string }{title, src}); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not render player",
+				"details": err.Error(),
+			})
+		}
+
+		c.Set("Content-Security-Policy", playerCSP(base, frameAncestors))
+		c.Set("X-Content-Type-Options", "nosniff")
+		c.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		// Signed embeds carry a token that expires, so they must not be cached
+		if video.Result.RequireSignedURLs {
+			c.Set("Cache-Control", "no-store")
+		} else {
+			c.Set("Cache-Control", "public, max-age=300")
+		}
+		c.Type("html", "utf-8")
+		return c.Send(page.Bytes())
+	})
+}
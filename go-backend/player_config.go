@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/url"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PlayerConfig is the account-wide branding applied to Stream player embeds
+type PlayerConfig struct {
+	PrimaryColor   string `json:"primaryColor" validate:"omitempty,hexcolor"`
+	LetterboxColor string `json:"letterboxColor" validate:"omitempty,hexcolor"`
+	Logo           string `json:"logo" validate:"omitempty,http_url,max=2048"`
+}
+
+// EmbedParams renders the config as query parameters for the Stream iframe URL
+func (p PlayerConfig) EmbedParams() string {
+	params := url.Values{}
+	if p.PrimaryColor != "" {
+		params.Set("primaryColor", p.PrimaryColor)
+	}
+	if p.LetterboxColor != "" {
+		params.Set("letterboxColor", p.LetterboxColor)
+	}
+	return params.Encode()
+}
+
+// playerConfigStore keeps one PlayerConfig per Cloudflare account
+type playerConfigStore struct {
+	mu      sync.RWMutex
+	configs map[string]PlayerConfig
+}
+
+func newPlayerConfigStore() *playerConfigStore {
+	return &playerConfigStore{configs: make(map[string]PlayerConfig)}
+}
+
+func (s *playerConfigStore) Get(accountID string) PlayerConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.configs[accountID]
+}
+
+func (s *playerConfigStore) Put(accountID string, config PlayerConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configs[accountID] = config
+}
+
+// registerPlayerConfigRoutes manages player defaults. Cloudflare has no account-level
+// player settings API (customization is passed per embed), so the backend holds them
+// and hands the frontend ready-made embed parameters.
+func registerPlayerConfigRoutes(app *fiber.App, config CloudflareConfig, store *playerConfigStore, timeout fiber.Handler) {
+	app.Get("/api/account/player-config", timeout, func(c *fiber.Ctx) error {
+		player := store.Get(accountConfig(c.UserContext(), config).AccountID)
+		return respond(c, 200, fiber.Map{
+			"playerConfig": player,
+			"embedParams":  player.EmbedParams(),
+		})
+	})
+
+	app.Post("/api/account/player-config", timeout, requireJSON(), func(c *fiber.Ctx) error {
+		var body PlayerConfig
+		if ok, err := bindBody(c, &body); !ok {
+			return err
+		}
+
+		store.Put(accountConfig(c.UserContext(), config).AccountID, body)
+		return respond(c, 200, fiber.Map{
+			"playerConfig": body,
+			"embedParams":  body.EmbedParams(),
+		})
+	})
+}
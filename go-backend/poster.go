@@ -0,0 +1,114 @@
+package main
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxPosterBytes caps the size of custom poster images kept in the store
+const maxPosterBytes = 5 << 20
+
+// posterContentTypes are the image formats accepted as custom posters
+var posterContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// posterURL is the backend proxy URL serving a video's custom poster
+func posterURL(publicBaseURL, uid string) string {
+	return publicBaseURL + "/api/video/" + uid + "/poster"
+}
+
+// applyCustomPoster points the DTO's thumbnail at the backend poster proxy when the
+// video has opted in to a custom poster. Cloudflare playback is unaffected.
+func applyCustomPoster(dto *VideoDTO, store VideoStore, publicBaseURL string) {
+	if record, ok := store.Get(dto.UID); ok && record.Poster != nil {
+		dto.Thumbnail = posterURL(publicBaseURL, dto.UID)
+		if dto.URLs != nil {
+			dto.URLs.Thumbnail = dto.Thumbnail
+		}
+	}
+}
+
+func registerPosterRoutes(app *fiber.App, store VideoStore, publicBaseURL string, timeout fiber.Handler) {
+	// Serve the custom poster image for a video
+	app.Get("/api/video/:uid/poster", timeout, func(c *fiber.Ctx) error {
+		record, ok := store.Get(c.Params("uid"))
+		if !ok || record.Poster == nil {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Video has no custom poster",
+			})
+		}
+
+		c.Set("Content-Type", record.PosterContentType)
+		c.Set("Cache-Control", "public, max-age=300")
+		return c.Send(record.Poster)
+	})
+
+	// Opt a video in to a custom poster by uploading an image
+	app.Post("/api/video/:uid/poster", timeout, func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		file, err := c.FormFile("image")
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "No poster image provided",
+				"details": err.Error(),
+			})
+		}
+		if file.Size > maxPosterBytes {
+			return c.Status(413).JSON(fiber.Map{
+				"error": "Poster image must be 5MB or smaller",
+			})
+		}
+
+		fileContent, err := file.Open()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not open file",
+				"details": err.Error(),
+			})
+		}
+		defer fileContent.Close()
+
+		image, err := io.ReadAll(io.LimitReader(fileContent, maxPosterBytes))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not read file",
+				"details": err.Error(),
+			})
+		}
+
+		contentType := http.DetectContentType(image)
+		if !posterContentTypes[contentType] {
+			return c.Status(415).JSON(fiber.Map{
+				"error":   "Poster must be a JPEG, PNG or WebP image",
+				"details": contentType,
+			})
+		}
+
+		record, _ := store.Get(uid)
+		record.UID = uid
+		record.Poster = image
+		record.PosterContentType = contentType
+		store.Put(record)
+
+		return respond(c, 200, fiber.Map{
+			"thumbnail": posterURL(publicBaseURL, uid),
+		})
+	})
+
+	// Revert to Cloudflare's own thumbnail
+	app.Delete("/api/video/:uid/poster", timeout, func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+		if record, ok := store.Get(uid); ok {
+			record.Poster = nil
+			record.PosterContentType = ""
+			store.Put(record)
+		}
+		return c.SendStatus(204)
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// PublicDetails is the branding Cloudflare's player shows alongside a video
+type PublicDetails struct {
+	Title       string `json:"title" validate:"max=100"`
+	ShareLink   string `json:"share_link" validate:"omitempty,http_url,max=2048"`
+	ChannelLink string `json:"channel_link" validate:"omitempty,http_url,max=2048"`
+	Logo        string `json:"logo" validate:"omitempty,http_url,max=2048"`
+}
+
+func registerPublicDetailsRoutes(app *fiber.App, config CloudflareConfig, timeout fiber.Handler) {
+	// Title, links and logo shown by the Stream player
+	app.Get("/api/video/:uid/public-details", timeout, func(c *fiber.Ctx) error {
+		result, err := fetchVideo(c.UserContext(), config, c.Params("uid"))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Failed to get video", result.Errors)
+		}
+
+		return respond(c, 200, fiber.Map{
+			"publicDetails": result.Result.PublicDetails,
+		})
+	})
+
+	app.Post("/api/video/:uid/public-details", timeout, requireJSON(), func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		var body PublicDetails
+		if ok, err := bindBody(c, &body); !ok {
+			return err
+		}
+
+		result, err := updateVideo(c.UserContext(), config, uid, fiber.Map{
+			"uid":           uid,
+			"publicDetails": body,
+		})
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to update video",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Failed to update public details", result.Errors)
+		}
+
+		return respond(c, 200, fiber.Map{
+			"publicDetails": result.Result.PublicDetails,
+		})
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultRetryAfter is assumed when Cloudflare rate-limits us without saying for how long
+const defaultRetryAfter = 60 * time.Second
+
+// rateLimitWarnFraction triggers a log line once remaining quota drops below it
+const rateLimitWarnFraction = 0.1
+
+// rateLimitedUntil is the unix time (seconds) until which Cloudflare asked us to back off
+var rateLimitedUntil atomic.Int64
+
+// rateLimitTransport inspects Cloudflare's rate-limit headers on every response
+type rateLimitTransport struct {
+	next http.RoundTripper
+}
+
+func (t rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		wait := parseRetryAfter(resp.Header.Get("Retry-After"))
+		rateLimitedUntil.Store(time.Now().Add(wait).Unix())
+		fmt.Printf("Cloudflare rate limit hit on %s %s, backing off for %s\n", req.Method, req.URL.Path, wait)
+		return resp, nil
+	}
+
+	if limit, remaining, ok := parseRateLimit(resp.Header); ok && float64(remaining) < float64(limit)*rateLimitWarnFraction {
+		fmt.Printf("Approaching Cloudflare rate limit: %d of %d requests remaining\n", remaining, limit)
+	}
+	return resp, nil
+}
+
+// parseRetryAfter reads a Retry-After value given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return defaultRetryAfter
+}
+
+// parseRateLimit reads the quota from either the "Ratelimit: limit=.., remaining=.."
+// header or the older X-RateLimit-Limit / X-RateLimit-Remaining pair
+func parseRateLimit(header http.Header) (limit, remaining int, ok bool) {
+	if value := header.Get("Ratelimit"); value != "" {
+		var haveLimit, haveRemaining bool
+		for _, part := range strings.Split(value, ",") {
+			key, raw, found := strings.Cut(strings.TrimSpace(part), "=")
+			if !found {
+				continue
+			}
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "limit":
+				limit, haveLimit = n, true
+			case "remaining":
+				remaining, haveRemaining = n, true
+			}
+		}
+		return limit, remaining, haveLimit && haveRemaining && limit > 0
+	}
+
+	limit, errLimit := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	remaining, errRemaining := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	return limit, remaining, errLimit == nil && errRemaining == nil && limit > 0
+}
+
+// retryAfterSeconds is how long clients should wait before retrying, rounded up
+func retryAfterSeconds() int {
+	wait := rateLimitedUntil.Load() - time.Now().Unix()
+	if wait < 1 {
+		return int(defaultRetryAfter / time.Second)
+	}
+	return int(wait)
+}
+
+// respondRateLimited relays Cloudflare's back-off to the client
+func respondRateLimited(c *fiber.Ctx, message string, errs []CloudflareError) error {
+	wait := retryAfterSeconds()
+	c.Set("Retry-After", strconv.Itoa(wait))
+	return c.Status(429).JSON(fiber.Map{
+		"error":      message,
+		"details":    errs,
+		"retryAfter": wait,
+	})
+}
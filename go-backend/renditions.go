@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Rendition is one variant stream listed in an HLS master playlist
+type Rendition struct {
+	Width            int     `json:"width,omitempty"`
+	Height           int     `json:"height,omitempty"`
+	Bandwidth        int     `json:"bandwidth"`
+	AverageBandwidth int     `json:"averageBandwidth,omitempty"`
+	FrameRate        float64 `json:"frameRate,omitempty"`
+	Codecs           string  `json:"codecs,omitempty"`
+}
+
+// parseAttributeList splits an HLS attribute list such as
+// BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2" into its values, unquoting
+// quoted strings. Commas inside quotes don't separate attributes.
+func parseAttributeList(list string) map[string]string {
+	attrs := make(map[string]string)
+	for list != "" {
+		name, rest, ok := strings.Cut(list, "=")
+		if !ok {
+			break
+		}
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[strings.TrimSpace(name)] = value
+		list = strings.TrimPrefix(rest, ",")
+	}
+	return attrs
+}
+
+// parseRenditions lists the EXT-X-STREAM-INF variants of a master playlist,
+// highest bandwidth first
+func parseRenditions(manifest []byte) []Rendition {
+	renditions := []Rendition{}
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for scanner.Scan() {
+		list, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "#EXT-X-STREAM-INF:")
+		if !ok {
+			continue
+		}
+
+		attrs := parseAttributeList(list)
+		rendition := Rendition{Codecs: attrs["CODECS"]}
+		rendition.Bandwidth, _ = strconv.Atoi(attrs["BANDWIDTH"])
+		rendition.AverageBandwidth, _ = strconv.Atoi(attrs["AVERAGE-BANDWIDTH"])
+		rendition.FrameRate, _ = strconv.ParseFloat(attrs["FRAME-RATE"], 64)
+		if width, height, ok := strings.Cut(attrs["RESOLUTION"], "x"); ok {
+			rendition.Width, _ = strconv.Atoi(width)
+			rendition.Height, _ = strconv.Atoi(height)
+		}
+		renditions = append(renditions, rendition)
+	}
+
+	sort.SliceStable(renditions, func(i, j int) bool {
+		return renditions[i].Bandwidth > renditions[j].Bandwidth
+	})
+	return renditions
+}
+
+func registerRenditionRoutes(app *fiber.App, config CloudflareConfig, timeout fiber.Handler) {
+	// Resolutions and bitrates available for a video, for custom quality selectors
+	app.Get("/api/video/:uid/renditions", timeout, func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		video, err := fetchVideo(c.UserContext(), config, uid)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !video.Success {
+			return respondCloudflareError(c, "Failed to get video", video.Errors)
+		}
+		if !video.Result.ReadyToStream {
+			return c.Status(409).JSON(fiber.Map{
+				"error": "Video is not ready yet, renditions are listed once encoding finishes",
+				"state": video.Result.Status.State,
+			})
+		}
+
+		urls, err := playbackURLs(c.UserContext(), config, video.Result)
+		if err != nil {
+			return respondSigningError(c, 502, "Could not build manifest URL", err)
+		}
+
+		_, manifest, err := fetchDeliveryAsset(c, urls.HLS)
+		if err != nil {
+			return c.Status(502).JSON(fiber.Map{
+				"error":   "Failed to fetch manifest",
+				"details": err.Error(),
+			})
+		}
+
+		return respond(c, 200, fiber.Map{
+			"uid":        uid,
+			"renditions": parseRenditions(manifest),
+		})
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Response envelope styles, chosen globally with RESPONSE_ENVELOPE
+const (
+	// envelopeRaw sends each endpoint's payload directly, as it always has been
+	envelopeRaw = "raw"
+	// envelopeWrapped sends {"data": ..., "meta": ...} from every endpoint
+	envelopeWrapped = "wrapped"
+)
+
+// responseEnvelope is the style successful JSON responses are written in.
+// Error responses keep their {"error", "details"} shape in both styles.
+var responseEnvelope = envelopeRaw
+
+// loadResponseEnvelope reads RESPONSE_ENVELOPE, defaulting to raw
+func loadResponseEnvelope() (string, error) {
+	switch envelope := os.Getenv("RESPONSE_ENVELOPE"); envelope {
+	case "":
+		return envelopeRaw, nil
+	case envelopeRaw, envelopeWrapped:
+		return envelope, nil
+	default:
+		return "", fmt.Errorf("RESPONSE_ENVELOPE must be %s or %s, got %q", envelopeRaw, envelopeWrapped, envelope)
+	}
+}
+
+// Pagination describes where a page of a list sits in the full collection
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	Count      int    `json:"count"`
+	HasMore    bool   `json:"hasMore"`
+	NextCursor string `json:"nextCursor"`
+}
+
+// respond writes a successful response: body as-is in raw mode, or as
+// {"data": body} when wrapped
+func respond(c *fiber.Ctx, status int, body interface{}) error {
+	if responseEnvelope == envelopeWrapped {
+		return c.Status(status).JSON(fiber.Map{
+			"data": body,
+		})
+	}
+	return c.Status(status).JSON(body)
+}
+
+// respondResult writes a response built around a single result. Raw mode keeps the
+// {"result": ..., <extra>} shape; wrapped mode moves the extra fields into meta.
+func respondResult(c *fiber.Ctx, status int, result interface{}, extra fiber.Map) error {
+	if responseEnvelope == envelopeWrapped {
+		body := fiber.Map{"data": result}
+		if len(extra) > 0 {
+			body["meta"] = extra
+		}
+		return c.Status(status).JSON(body)
+	}
+
+	body := fiber.Map{"result": result}
+	for key, value := range extra {
+		body[key] = value
+	}
+	return c.Status(status).JSON(body)
+}
+
+// cloudflareMeta is the status half of a Cloudflare envelope, sent alongside a result
+func cloudflareMeta(success bool, errs []CloudflareError, messages []string) fiber.Map {
+	return fiber.Map{
+		"success":  success,
+		"errors":   errs,
+		"messages": messages,
+	}
+}
+
+// respondList writes one page of a list. Raw mode keeps {"result": items,
+// "nextCursor": ...}; wrapped mode reports the full pagination in meta.
+func respondList(c *fiber.Ctx, items interface{}, page Pagination) error {
+	if responseEnvelope == envelopeWrapped {
+		return c.JSON(fiber.Map{
+			"data": items,
+			"meta": fiber.Map{"pagination": page},
+		})
+	}
+	return c.JSON(fiber.Map{
+		"result":     items,
+		"nextCursor": page.NextCursor,
+	})
+}
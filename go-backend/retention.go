@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Cloudflare accepts scheduledDeletion between 30 and 1096 days from now
+const (
+	minScheduledDeletion = 30 * 24 * time.Hour
+	maxScheduledDeletion = 1096 * 24 * time.Hour
+)
+
+// validateRetentionWindow checks a keepalive window against Cloudflare's limits
+func validateRetentionWindow(window time.Duration) error {
+	if window < minScheduledDeletion || window > maxScheduledDeletion {
+		return fmt.Errorf("window must be between %s and %s", minScheduledDeletion, maxScheduledDeletion)
+	}
+	return nil
+}
+
+func registerRetentionRoutes(app *fiber.App, config CloudflareConfig, window time.Duration, timeout fiber.Handler) {
+	if err := validateRetentionWindow(window); err != nil {
+		fmt.Printf("Warning: SCHEDULED_DELETION_WINDOW invalid (%v), using %s\n", err, minScheduledDeletion)
+		window = minScheduledDeletion
+	}
+
+	// Keepalive: push the video's scheduled deletion a full window past now
+	app.Post("/api/video/:uid/touch", timeout, func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		deleteAt := time.Now().Add(window).UTC().Truncate(time.Second)
+		result, err := updateVideo(c.UserContext(), config, uid, fiber.Map{
+			"uid":               uid,
+			"scheduledDeletion": deleteAt.Format(time.RFC3339),
+		})
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to update video",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Failed to extend scheduled deletion", result.Errors)
+		}
+
+		return respond(c, 200, fiber.Map{
+			"scheduledDeletion": result.Result.ScheduledDeletion,
+		})
+	})
+}
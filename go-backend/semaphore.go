@@ -0,0 +1,80 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// PrioritySemaphore limits concurrent work to a fixed number of slots. Freed slots
+// go to waiting high-priority callers before normal ones; within a priority,
+// callers are served in arrival order.
+type PrioritySemaphore struct {
+	mu     sync.Mutex
+	slots  int
+	inUse  int
+	high   *list.List
+	normal *list.List
+}
+
+func newPrioritySemaphore(slots int) *PrioritySemaphore {
+	if slots < 1 {
+		slots = 1
+	}
+	return &PrioritySemaphore{slots: slots, high: list.New(), normal: list.New()}
+}
+
+// Acquire blocks until a slot is free or ctx is done
+func (s *PrioritySemaphore) Acquire(ctx context.Context, high bool) error {
+	s.mu.Lock()
+	// Only take a free slot directly if nobody who should go first is waiting
+	ahead := s.high.Len()
+	if !high {
+		ahead += s.normal.Len()
+	}
+	if s.inUse < s.slots && ahead == 0 {
+		s.inUse++
+		s.mu.Unlock()
+		return nil
+	}
+
+	queue := s.normal
+	if high {
+		queue = s.high
+	}
+	ready := make(chan struct{})
+	elem := queue.PushBack(ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-ready:
+			// Granted just as we gave up; hand the slot on
+			s.mu.Unlock()
+			s.Release()
+		default:
+			queue.Remove(elem)
+			s.mu.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot, passing it straight to the next waiter if there is one
+func (s *PrioritySemaphore) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, queue := range []*list.List{s.high, s.normal} {
+		if front := queue.Front(); front != nil {
+			queue.Remove(front)
+			close(front.Value.(chan struct{}))
+			return
+		}
+	}
+	s.inUse--
+}
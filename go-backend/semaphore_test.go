@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waitQueued blocks until the semaphore has n waiters in total
+func waitQueued(t *testing.T, s *PrioritySemaphore, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		queued := s.high.Len() + s.normal.Len()
+		s.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d queued callers", n)
+}
+
+func TestPrioritySemaphoreReleasesHighFirst(t *testing.T) {
+	s := newPrioritySemaphore(1)
+	if err := s.Acquire(context.Background(), false); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	granted := make(chan string, 2)
+	acquire := func(name string, high bool) {
+		if err := s.Acquire(context.Background(), high); err != nil {
+			t.Errorf("Acquire %s: %v", name, err)
+			return
+		}
+		granted <- name
+	}
+
+	// The low-priority caller queues first, then the high-priority one
+	go acquire("low", false)
+	waitQueued(t, s, 1)
+	go acquire("high", true)
+	waitQueued(t, s, 2)
+
+	s.Release()
+	if first := <-granted; first != "high" {
+		t.Fatalf("first release went to %s, want high", first)
+	}
+	select {
+	case name := <-granted:
+		t.Fatalf("%s was granted while the slot was still held", name)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	s.Release()
+	if second := <-granted; second != "low" {
+		t.Fatalf("second release went to %s, want low", second)
+	}
+	s.Release()
+}
+
+func TestPrioritySemaphoreGivesUpOnCancel(t *testing.T) {
+	s := newPrioritySemaphore(1)
+	if err := s.Acquire(context.Background(), false); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx, true); err == nil {
+		t.Fatal("Acquire succeeded while the only slot was held")
+	}
+	waitQueued(t, s, 0)
+
+	// The abandoned waiter must not swallow the slot
+	s.Release()
+	if err := s.Acquire(context.Background(), false); err != nil {
+		t.Fatalf("Acquire after release: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// shareURL is the backend link that redirects to a video's player
+func shareURL(publicBaseURL, shareID string) string {
+	return publicBaseURL + "/v/" + shareID
+}
+
+// newShareID returns a random, URL-safe short identifier
+func newShareID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func registerShareRoutes(app *fiber.App, config CloudflareConfig, store VideoStore, publicBaseURL string, timeout fiber.Handler) {
+	// Serializes share ID assignment so concurrent requests agree on one link
+	var assign sync.Mutex
+
+	// A short link to the video that stays the same however its playback URLs change
+	app.Get("/api/video/:uid/share", timeout, func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		// Only hand out links for videos Cloudflare knows about
+		video, err := fetchVideo(c.UserContext(), config, uid)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !video.Success {
+			return respondCloudflareError(c, "Failed to get video", video.Errors)
+		}
+
+		assign.Lock()
+		defer assign.Unlock()
+
+		record, _ := store.Get(uid)
+		if record.ShareID == "" {
+			shareID, err := newShareID()
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Could not create share link",
+					"details": err.Error(),
+				})
+			}
+			record.UID = uid
+			record.ShareID = shareID
+			store.Put(record)
+		}
+
+		return respond(c, 200, fiber.Map{
+			"shareId": record.ShareID,
+			"url":     shareURL(publicBaseURL, record.ShareID),
+		})
+	})
+
+	// Follow a share link to the Stream player, signed when the video is private
+	app.Get("/v/:shareId", timeout, func(c *fiber.Ctx) error {
+		record, ok := store.GetByShareID(c.Params("shareId"))
+		if !ok {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Share link not found",
+			})
+		}
+
+		video, err := fetchVideo(c.UserContext(), config, record.UID)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !video.Success {
+			return respondCloudflareError(c, "Failed to get video", video.Errors)
+		}
+
+		urls, err := playbackURLs(c.UserContext(), config, video.Result)
+		if err != nil {
+			return respondSigningError(c, 502, "Could not build player URL", err)
+		}
+
+		// A signed target expires, so that redirect must not be cached
+		if video.Result.RequireSignedURLs {
+			c.Set("Cache-Control", "no-store")
+		} else {
+			c.Set("Cache-Control", "public, max-age=300")
+		}
+		return c.Redirect(urls.Iframe, fiber.StatusFound)
+	})
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SigningKey is a Stream signing key. Cloudflare only returns the private key
+// material (PEM and JWK, both base64 encoded) when the key is created.
+type SigningKey struct {
+	ID      string `json:"id"`
+	PEM     string `json:"pem,omitempty"`
+	JWK     string `json:"jwk,omitempty"`
+	Created string `json:"created"`
+}
+
+// SigningKeyResponse represents Cloudflare's response when creating a signing key
+type SigningKeyResponse struct {
+	Result   SigningKey        `json:"result"`
+	Success  bool              `json:"success"`
+	Errors   []CloudflareError `json:"errors"`
+	Messages []string          `json:"messages"`
+}
+
+// SigningKeyListResponse represents Cloudflare's response when listing signing keys
+type SigningKeyListResponse struct {
+	Result   []SigningKey      `json:"result"`
+	Success  bool              `json:"success"`
+	Errors   []CloudflareError `json:"errors"`
+	Messages []string          `json:"messages"`
+}
+
+// parseSigningKey decodes a signing key as Cloudflare hands it out: a base64
+// encoded PEM holding an RSA private key. Errors never echo the key itself.
+func parseSigningKey(encoded string) (*rsa.PrivateKey, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("signing key PEM is not base64 encoded")
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("signing key contains no PEM block")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("signing key is not an RSA private key")
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key is not an RSA private key")
+	}
+	return key, nil
+}
+
+// StreamTokenClaims is the payload of a playback token signed with a Stream
+// signing key. Cloudflare reads the video from sub and the key from kid.
+type StreamTokenClaims struct {
+	Subject     string       `json:"sub"`
+	KeyID       string       `json:"kid"`
+	ExpiresAt   int64        `json:"exp"`
+	NotBefore   int64        `json:"nbf,omitempty"`
+	AccessRules []AccessRule `json:"accessRules,omitempty"`
+}
+
+// signStreamToken mints an RS256 compact JWT that Cloudflare accepts in place of a
+// video UID, saving the round-trip to the token endpoint
+func signStreamToken(key *rsa.PrivateKey, claims StreamTokenClaims) (string, error) {
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"kid": claims.KeyID,
+	})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// validateSigningKey checks the account's active signing key is either fully
+// configured with a parseable key or not configured at all
+func validateSigningKey(config CloudflareConfig) error {
+	if config.SigningKeyID == "" && config.SigningKeyPEM == "" {
+		return nil
+	}
+	if config.SigningKeyID == "" || config.SigningKeyPEM == "" {
+		return errors.New("signing key needs both an ID and a PEM")
+	}
+	if _, err := parseSigningKey(config.SigningKeyPEM); err != nil {
+		return fmt.Errorf("signing key %s: %w", config.SigningKeyID, err)
+	}
+	return nil
+}
+
+// errSigningNotConfigured means a private video needed a token but the account
+// has no signing key configured
+var errSigningNotConfigured = errors.New("signing not configured: set a signing key ID and PEM for this account")
+
+// TokenError is Cloudflare refusing to create a playback token, carrying its
+// errors so callers can answer with the matching status
+type TokenError struct {
+	Errors []CloudflareError
+}
+
+func (e *TokenError) Error() string {
+	return fmt.Sprintf("token creation failed: %v", e.Errors)
+}
+
+// signingConfigured reports whether the account has a signing key to sign tokens with
+func signingConfigured(config CloudflareConfig) bool {
+	return config.SigningKeyID != "" && config.SigningKeyPEM != ""
+}
+
+// respondSigningError answers 501 when err is down to missing signing config,
+// Cloudflare's own status when it refused the token, and otherwise status with
+// the given message
+func respondSigningError(c *fiber.Ctx, status int, message string, err error) error {
+	if errors.Is(err, errSigningNotConfigured) {
+		return c.Status(501).JSON(fiber.Map{
+			"error":   "Signing not configured",
+			"details": err.Error(),
+		})
+	}
+	var tokenErr *TokenError
+	if errors.As(err, &tokenErr) {
+		return respondCloudflareError(c, message, tokenErr.Errors)
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
+
+func registerSigningKeyRoutes(app *fiber.App, config CloudflareConfig, timeout fiber.Handler) {
+	// List the account's signing keys, flagging the one tokens are signed with
+	app.Get("/api/signing-keys", timeout, func(c *fiber.Ctx) error {
+		result, err := callCloudflare[SigningKeyListResponse](c.UserContext(), config, "GET", "/stream/keys", nil)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to list signing keys",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Failed to list signing keys", result.Errors)
+		}
+
+		activeID := accountConfig(c.UserContext(), config).SigningKeyID
+		keys := make([]fiber.Map, 0, len(result.Result))
+		for _, key := range result.Result {
+			keys = append(keys, fiber.Map{
+				"id":      key.ID,
+				"created": key.Created,
+				"active":  key.ID == activeID,
+			})
+		}
+		return respondResult(c, 200, keys, nil)
+	})
+
+	// Create a signing key. The private key is only ever returned here, so the
+	// response must not be cached anywhere along the way.
+	app.Post("/api/signing-keys", timeout, func(c *fiber.Ctx) error {
+		result, err := callCloudflare[SigningKeyResponse](c.UserContext(), config, "POST", "/stream/keys", nil)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to create signing key",
+				"details": err.Error(),
+			})
+		}
+		if !result.Success {
+			return respondCloudflareError(c, "Signing key creation failed", result.Errors)
+		}
+
+		c.Set("Cache-Control", "no-store")
+		return respondResult(c, 201, result.Result, nil)
+	})
+
+	// Delete a signing key, refusing to remove the one tokens are signed with
+	app.Delete("/api/signing-keys/:id", timeout, func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if id == accountConfig(c.UserContext(), config).SigningKeyID {
+			return c.Status(409).JSON(fiber.Map{
+				"error": "Signing key is the configured active key",
+			})
+		}
+
+		status, err := deleteResource(c.UserContext(), config, "/stream/keys/"+id)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to delete signing key",
+				"details": err.Error(),
+			})
+		}
+		if status == 404 {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Signing key not found",
+			})
+		}
+		if status >= 300 {
+			return c.Status(502).JSON(fiber.Map{
+				"error":  "Cloudflare refused to delete the signing key",
+				"status": status,
+			})
+		}
+		return c.SendStatus(204)
+	})
+}
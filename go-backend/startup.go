@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// startupCheck makes one authenticated list call and describes what went wrong,
+// separating rejected credentials from network problems
+func startupCheck(config CloudflareConfig, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := newCloudflareRequest(ctx, config, "GET", "/stream?limit=1", nil)
+	if err != nil {
+		return fmt.Errorf("could not build request: %w", err)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("network error reaching %s: %w", config.BaseURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return fmt.Errorf("HTTP 401: CLOUDFLARE_API_TOKEN was rejected")
+	case http.StatusForbidden:
+		return fmt.Errorf("HTTP 403: token lacks Stream access to account %s", config.AccountID)
+	}
+
+	var result VideoListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("HTTP %d with an unreadable body: %w", resp.StatusCode, err)
+	}
+	if !result.Success {
+		return fmt.Errorf("HTTP %d: %v", resp.StatusCode, result.Errors)
+	}
+	return nil
+}
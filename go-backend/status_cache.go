@@ -0,0 +1,90 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache holds values for a fixed TTL. It is bounded, evicting the least
+// recently used entry once full.
+type lruCache[V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+func newLRUCache[V any](ttl time.Duration, max int) *lruCache[V] {
+	if max < 1 {
+		max = 1
+	}
+	return &lruCache[V]{
+		ttl:     ttl,
+		max:     max,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached value for key if it hasn't expired
+func (s *lruCache[V]) Get(key string) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var zero V
+	elem, ok := s.entries[key]
+	if !ok {
+		return zero, false
+	}
+	entry := elem.Value.(*lruEntry[V])
+	if time.Now().After(entry.expiresAt) {
+		s.order.Remove(elem)
+		delete(s.entries, key)
+		return zero, false
+	}
+	s.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Put stores a value for the cache's TTL, evicting the oldest entry when full
+func (s *lruCache[V]) Put(key string, value V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := time.Now().Add(s.ttl)
+	if elem, ok := s.entries[key]; ok {
+		entry := elem.Value.(*lruEntry[V])
+		entry.value, entry.expiresAt = value, expiresAt
+		s.order.MoveToFront(elem)
+		return
+	}
+
+	s.entries[key] = s.order.PushFront(&lruEntry[V]{key: key, value: value, expiresAt: expiresAt})
+	if s.order.Len() > s.max {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+// newStatusCache briefly holds video status responses so clients polling while
+// a video encodes don't each hit Cloudflare. Cached responses must be treated
+// as read-only.
+func newStatusCache(ttl time.Duration, max int) *lruCache[*VideoUploadResponse] {
+	return newLRUCache[*VideoUploadResponse](ttl, max)
+}
+
+// newSignedURLCache holds the signed playback URL bundle served with a private
+// video's status, so each poll doesn't mint a new token. Its TTL must stay
+// below signedAssetTTL so a cached bundle is never handed out near expiry.
+func newSignedURLCache(ttl time.Duration, max int) *lruCache[PlaybackURLs] {
+	return newLRUCache[PlaybackURLs](ttl, max)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// statusVersionHistory is how many past versions of a video are kept to diff
+// against; clients further behind get the full status
+const statusVersionHistory = 16
+
+// StatusVersions numbers each distinct Cloudflare payload seen for a video, so
+// pollers can ask for only what changed since the version they last saw. It is
+// bounded like the status cache, forgetting the least recently polled video once full.
+// Versions come from one counter for all videos, so a video that is forgotten
+// and seen again never reuses a version a client may still hold.
+type StatusVersions struct {
+	mu      sync.Mutex
+	max     int
+	last    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type videoVersions struct {
+	key     string
+	version int
+	hash    [sha256.Size]byte
+	// snapshots holds the top-level status fields at each remembered version,
+	// oldest first in history
+	snapshots map[int]map[string]json.RawMessage
+	history   []int
+}
+
+func newStatusVersions(max int) *StatusVersions {
+	if max < 1 {
+		max = 1
+	}
+	return &StatusVersions{
+		max:     max,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Observe records the video's current Cloudflare payload and the status fields
+// built from it, returning the current version. The version only moves when
+// the payload differs from the last one seen.
+func (s *StatusVersions) Observe(key string, payload []byte, fields map[string]json.RawMessage) int {
+	hash := sha256.Sum256(payload)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		entry := elem.Value.(*videoVersions)
+		s.order.MoveToFront(elem)
+		if entry.hash != hash {
+			s.last++
+			entry.version = s.last
+			entry.hash = hash
+			entry.history = append(entry.history, entry.version)
+			if len(entry.history) > statusVersionHistory {
+				delete(entry.snapshots, entry.history[0])
+				entry.history = entry.history[1:]
+			}
+		}
+		// Fields derived on our side, such as signed URLs, may change between
+		// polls, so the snapshot always reflects the latest response
+		entry.snapshots[entry.version] = fields
+		return entry.version
+	}
+
+	s.last++
+	entry := &videoVersions{
+		key:       key,
+		version:   s.last,
+		hash:      hash,
+		snapshots: map[int]map[string]json.RawMessage{s.last: fields},
+		history:   []int{s.last},
+	}
+	s.entries[key] = s.order.PushFront(entry)
+	if s.order.Len() > s.max {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*videoVersions).key)
+	}
+	return entry.version
+}
+
+// Snapshot returns the status fields as they were at version, if still remembered
+func (s *StatusVersions) Snapshot(key string, version int) (map[string]json.RawMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	fields, ok := elem.Value.(*videoVersions).snapshots[version]
+	return fields, ok
+}
+
+// statusETag is the entity tag for a status version
+func statusETag(version int) string {
+	return `"v` + strconv.Itoa(version) + `"`
+}
+
+// parseStatusVersion accepts a version as sent in ?since: a bare number such as
+// 3, or the ETag form "v3" with or without quotes
+func parseStatusVersion(since string) (int, bool) {
+	since = strings.TrimPrefix(strings.Trim(since, `"`), "v")
+	version, err := strconv.Atoi(since)
+	return version, err == nil && version > 0
+}
+
+// statusFields splits a serialized status into its top-level fields
+func statusFields(dto VideoDTO) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(dto)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+// derivedStatusFields are built on our side rather than read from Cloudflare.
+// Signed URLs carry a token that is renewed on its own schedule, so they are
+// only resent alongside a real change, never as the change itself.
+var derivedStatusFields = map[string]bool{"urls": true}
+
+// diffStatusFields returns the fields of current that are new or differ from
+// previous, and the names of fields previous had that current lacks
+func diffStatusFields(previous, current map[string]json.RawMessage) (map[string]json.RawMessage, []string) {
+	changed := make(map[string]json.RawMessage)
+	for name, value := range current {
+		if derivedStatusFields[name] {
+			continue
+		}
+		if old, ok := previous[name]; !ok || !bytes.Equal(old, value) {
+			changed[name] = value
+		}
+	}
+	if len(changed) > 0 {
+		for name := range derivedStatusFields {
+			if value, ok := current[name]; ok {
+				changed[name] = value
+			}
+		}
+	}
+	removed := []string{}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	return changed, removed
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// VideoRecord is what the backend remembers about a video beyond Cloudflare's own data
+type VideoRecord struct {
+	UID string
+	// AccountID is the Cloudflare account the video lives in, so background work
+	// reaches it through the right account in multi-tenant deployments
+	AccountID string
+
+	// Custom poster shown instead of Cloudflare's frame thumbnail, if set
+	Poster            []byte
+	PosterContentType string
+
+	// Direct creator uploads are tracked from URL creation until Cloudflare finishes with them
+	UploadState     string
+	UploadCreatedAt time.Time
+	UploadExpiresAt time.Time
+
+	// Short ID of the video's share link, once one has been requested
+	ShareID string
+}
+
+// VideoStore persists backend-side video records keyed by UID
+type VideoStore interface {
+	Get(uid string) (VideoRecord, bool)
+	GetByShareID(shareID string) (VideoRecord, bool)
+	Put(record VideoRecord)
+	// Update calls fn with the record for uid (false when there is none) and
+	// stores what it returns when it also returns true. No other write to the
+	// store happens in between, so fn can decide based on the current record.
+	Update(uid string, fn func(record VideoRecord, ok bool) (VideoRecord, bool))
+	Delete(uid string)
+	List() []VideoRecord
+}
+
+// memoryVideoStore is the default VideoStore; it is lost on restart
+type memoryVideoStore struct {
+	mu      sync.RWMutex
+	records map[string]VideoRecord
+	shares  map[string]string // share ID -> UID
+}
+
+func newMemoryVideoStore() *memoryVideoStore {
+	return &memoryVideoStore{
+		records: make(map[string]VideoRecord),
+		shares:  make(map[string]string),
+	}
+}
+
+func (m *memoryVideoStore) Get(uid string) (VideoRecord, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	record, ok := m.records[uid]
+	return record, ok
+}
+
+func (m *memoryVideoStore) GetByShareID(shareID string) (VideoRecord, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	uid, ok := m.shares[shareID]
+	if !ok {
+		return VideoRecord{}, false
+	}
+	record, ok := m.records[uid]
+	return record, ok
+}
+
+func (m *memoryVideoStore) Put(record VideoRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.put(record)
+}
+
+func (m *memoryVideoStore) Update(uid string, fn func(record VideoRecord, ok bool) (VideoRecord, bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.records[uid]
+	if updated, write := fn(record, ok); write {
+		updated.UID = uid
+		m.put(updated)
+	}
+}
+
+// put stores record; callers hold m.mu
+func (m *memoryVideoStore) put(record VideoRecord) {
+	if old, ok := m.records[record.UID]; ok && old.ShareID != "" && old.ShareID != record.ShareID {
+		delete(m.shares, old.ShareID)
+	}
+	if record.ShareID != "" {
+		m.shares[record.ShareID] = record.UID
+	}
+	m.records[record.UID] = record
+}
+
+func (m *memoryVideoStore) Delete(uid string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if old, ok := m.records[uid]; ok && old.ShareID != "" {
+		delete(m.shares, old.ShareID)
+	}
+	delete(m.records, uid)
+}
+
+func (m *memoryVideoStore) List() []VideoRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	records := make([]VideoRecord, 0, len(m.records))
+	for _, record := range m.records {
+		records = append(records, record)
+	}
+	return records
+}
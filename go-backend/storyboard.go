@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func registerStoryboardRoutes(app *fiber.App, config CloudflareConfig, timeout fiber.Handler) {
+	// Storyboard manifest for scrubbing previews, signed for private videos
+	app.Get("/api/video/:uid/storyboard", timeout, func(c *fiber.Ctx) error {
+		video, err := fetchVideo(c.UserContext(), config, c.Params("uid"))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !video.Success {
+			return respondCloudflareError(c, "Failed to get video", video.Errors)
+		}
+
+		// Storyboards are only generated once encoding has finished
+		if !video.Result.ReadyToStream {
+			return c.Status(409).JSON(fiber.Map{
+				"error": "Video is not ready yet, storyboard unavailable",
+				"state": video.Result.Status.State,
+			})
+		}
+
+		urls, err := playbackURLs(c.UserContext(), config, video.Result)
+		if err != nil {
+			return respondSigningError(c, 502, "Could not build storyboard URL", err)
+		}
+		storyboardURL := urls.Storyboard
+
+		req, err := http.NewRequestWithContext(c.UserContext(), "GET", storyboardURL, nil)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not create request",
+				"details": err.Error(),
+			})
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return c.Status(502).JSON(fiber.Map{
+				"error":   "Failed to fetch storyboard",
+				"details": err.Error(),
+			})
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return c.Status(502).JSON(fiber.Map{
+				"error":  "Cloudflare returned an error for the storyboard",
+				"status": resp.StatusCode,
+			})
+		}
+
+		contents, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not read storyboard",
+				"details": err.Error(),
+			})
+		}
+		if !json.Valid(contents) {
+			return c.Status(502).JSON(fiber.Map{
+				"error": "Storyboard response was not valid JSON",
+			})
+		}
+
+		return respond(c, 200, fiber.Map{
+			"url":        storyboardURL,
+			"storyboard": json.RawMessage(contents),
+		})
+	})
+}
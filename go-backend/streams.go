@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// streamSlotKey is the Locals key holding the slot taken by limitStreams
+const streamSlotKey = "streamSlot"
+
+// StreamLimiter counts open long-lived connections (SSE, long-poll) per client
+type StreamLimiter struct {
+	mu     sync.Mutex
+	max    int
+	active map[string]int
+}
+
+func newStreamLimiter(max int) *StreamLimiter {
+	return &StreamLimiter{max: max, active: make(map[string]int)}
+}
+
+// Acquire takes a slot for the client, reporting false when it already holds the maximum
+func (l *StreamLimiter) Acquire(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[key] >= l.max {
+		return false
+	}
+	l.active[key]++
+	return true
+}
+
+// Release returns a slot taken by Acquire
+func (l *StreamLimiter) Release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[key] <= 1 {
+		delete(l.active, key)
+		return
+	}
+	l.active[key]--
+}
+
+// streamSlot lets a streaming handler keep its slot past the handler's return
+type streamSlot struct {
+	release  func()
+	detached bool
+}
+
+// limitStreams rejects a client with 429 once it holds too many open streams.
+// The slot is released when the handler returns unless the handler detaches it
+// with detachStreamSlot to release it itself when the stream closes.
+func limitStreams(limiter *StreamLimiter) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := clientKey(c)
+		if !limiter.Acquire(key) {
+			return c.Status(429).JSON(fiber.Map{
+				"error": "Too many open streaming connections",
+				"limit": limiter.max,
+			})
+		}
+
+		var once sync.Once
+		slot := &streamSlot{release: func() { once.Do(func() { limiter.Release(key) }) }}
+		c.Locals(streamSlotKey, slot)
+
+		err := c.Next()
+		if !slot.detached {
+			slot.release()
+		}
+		return err
+	}
+}
+
+// detachStreamSlot hands responsibility for releasing the connection slot to the
+// caller, for handlers whose response body keeps streaming after they return
+func detachStreamSlot(c *fiber.Ctx) func() {
+	if slot, ok := c.Locals(streamSlotKey).(*streamSlot); ok {
+		slot.detached = true
+		return slot.release
+	}
+	return func() {}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// accountContextKey is the context key holding the CloudflareConfig selected for a request
+type accountContextKey struct{}
+
+// creatorContextKey is the context key holding the creator named in a caller's account JWT
+type creatorContextKey struct{}
+
+// TenantClaims are the claims read from a caller's account JWT. Creator, when
+// set, is the end user the caller acts for.
+type TenantClaims struct {
+	Account   string `json:"account"`
+	Creator   string `json:"creator"`
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf"`
+}
+
+// withAccount returns a context that routes Cloudflare calls to the given account
+func withAccount(ctx context.Context, config CloudflareConfig) context.Context {
+	return context.WithValue(ctx, accountContextKey{}, config)
+}
+
+// accountConfig returns the account selected for this request, or def when none was
+func accountConfig(ctx context.Context, def CloudflareConfig) CloudflareConfig {
+	if config, ok := ctx.Value(accountContextKey{}).(CloudflareConfig); ok {
+		return config
+	}
+	return def
+}
+
+// withCreator returns a context recording the authenticated creator of a request
+func withCreator(ctx context.Context, creator string) context.Context {
+	return context.WithValue(ctx, creatorContextKey{}, creator)
+}
+
+// requestCreator returns the authenticated creator of this request, or "" when
+// the caller's token names none
+func requestCreator(ctx context.Context) string {
+	creator, _ := ctx.Value(creatorContextKey{}).(string)
+	return creator
+}
+
+// loadTenantAccounts reads the aliases in CLOUDFLARE_ACCOUNT_ALIASES and, for each
+// alias, its CLOUDFLARE_ACCOUNT_<ALIAS>_ID and CLOUDFLARE_ACCOUNT_<ALIAS>_TOKEN,
+// plus an optional _SIGNING_KEY_ID and _SIGNING_KEY_PEM pair.
+func loadTenantAccounts(base CloudflareConfig) (map[string]CloudflareConfig, error) {
+	accounts := make(map[string]CloudflareConfig)
+	for _, alias := range strings.Split(os.Getenv("CLOUDFLARE_ACCOUNT_ALIASES"), ",") {
+		alias = strings.TrimSpace(alias)
+		if alias == "" {
+			continue
+		}
+		prefix := "CLOUDFLARE_ACCOUNT_" + strings.ToUpper(alias)
+		config := CloudflareConfig{
+			AccountID:     os.Getenv(prefix + "_ID"),
+			APIToken:      os.Getenv(prefix + "_TOKEN"),
+			BaseURL:       base.BaseURL,
+			SigningKeyID:  os.Getenv(prefix + "_SIGNING_KEY_ID"),
+			SigningKeyPEM: os.Getenv(prefix + "_SIGNING_KEY_PEM"),
+		}
+		if config.AccountID == "" || config.APIToken == "" {
+			return nil, fmt.Errorf("account alias %q needs %s_ID and %s_TOKEN", alias, prefix, prefix)
+		}
+		if err := validateSigningKey(config); err != nil {
+			return nil, fmt.Errorf("account alias %q: %w", alias, err)
+		}
+		accounts[alias] = config
+	}
+	return accounts, nil
+}
+
+// loadPublicKey parses a PEM-encoded RSA or ECDSA public key
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	default:
+		return nil, errors.New("public key must be RSA or ECDSA")
+	}
+}
+
+// tenantClockSkew is how far the caller's clock may be off from ours when the
+// validity window of an account JWT is checked
+const tenantClockSkew = 30 * time.Second
+
+// verifyTenantJWT checks an RS256 or ES256 compact JWT against the public key
+// and returns its claims if the signature and validity window are good. The
+// algorithm must match the key type, so "none" and HMAC tokens are refused, and
+// every token must carry an exp.
+func verifyTenantJWT(token string, key crypto.PublicKey, now time.Time) (*TenantClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("malformed token header")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, errors.New("malformed token header")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if header.Alg != "RS256" {
+			return nil, fmt.Errorf("unexpected signing algorithm %q", header.Alg)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, errors.New("invalid token signature")
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg != "ES256" || len(signature) != 64 {
+			return nil, fmt.Errorf("unexpected signing algorithm %q", header.Alg)
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return nil, errors.New("invalid token signature")
+		}
+	default:
+		return nil, errors.New("unsupported public key type")
+	}
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+	var claims TenantClaims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+
+	skew := int64(tenantClockSkew / time.Second)
+	if claims.ExpiresAt == 0 {
+		return nil, errors.New("token has no expiry")
+	}
+	if now.Unix() >= claims.ExpiresAt+skew {
+		return nil, errors.New("token has expired")
+	}
+	if claims.NotBefore != 0 && now.Unix() < claims.NotBefore-skew {
+		return nil, errors.New("token is not valid yet")
+	}
+	return &claims, nil
+}
+
+// selectTenantAccount routes a request to the Cloudflare account named in the
+// caller's signed JWT. It is only installed in tenant mode, where requests
+// without a bearer token are refused rather than given the default account.
+// Cloudflare's webhook calls carry no token and are authenticated by signature.
+func selectTenantAccount(key crypto.PublicKey, accounts map[string]CloudflareConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Path() == "/api/webhooks/cloudflare" {
+			return c.Next()
+		}
+
+		auth := c.Get("Authorization")
+		if auth == "" {
+			c.Set("WWW-Authenticate", "Bearer")
+			return c.Status(401).JSON(fiber.Map{
+				"error": "An account token is required",
+			})
+		}
+
+		token, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok {
+			return c.Status(403).JSON(fiber.Map{
+				"error": "Authorization must be a Bearer token",
+			})
+		}
+
+		claims, err := verifyTenantJWT(token, key, time.Now())
+		if err != nil {
+			return c.Status(403).JSON(fiber.Map{
+				"error":   "Invalid account token",
+				"details": err.Error(),
+			})
+		}
+
+		config, ok := accounts[claims.Account]
+		if !ok {
+			return c.Status(403).JSON(fiber.Map{
+				"error": "Token does not grant access to a known account",
+			})
+		}
+
+		ctx := withAccount(c.UserContext(), config)
+		if claims.Creator != "" {
+			ctx = withCreator(ctx, claims.Creator)
+		}
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// encodeJWTPart base64url-encodes v as JSON, as in a compact JWT
+func encodeJWTPart(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signTenantJWT builds an RS256 JWT with the given header algorithm and claims
+func signTenantJWT(t *testing.T, key *rsa.PrivateKey, alg string, claims map[string]interface{}) string {
+	t.Helper()
+	input := encodeJWTPart(t, map[string]string{"alg": alg, "typ": "JWT"}) + "." + encodeJWTPart(t, claims)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("SignPKCS1v15: %v", err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifyTenantJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	exp := now.Add(time.Hour).Unix()
+
+	for _, tc := range []struct {
+		name   string
+		token  string
+		wantOK bool
+	}{
+		{"valid", signTenantJWT(t, key, "RS256", map[string]interface{}{"account": "a", "exp": exp}), true},
+		{"no exp", signTenantJWT(t, key, "RS256", map[string]interface{}{"account": "a"}), false},
+		{"expired", signTenantJWT(t, key, "RS256", map[string]interface{}{"account": "a", "exp": now.Add(-time.Minute).Unix()}), false},
+		{"expired within skew", signTenantJWT(t, key, "RS256", map[string]interface{}{"account": "a", "exp": now.Add(-10 * time.Second).Unix()}), true},
+		{"not valid yet", signTenantJWT(t, key, "RS256", map[string]interface{}{"account": "a", "exp": exp, "nbf": now.Add(time.Minute).Unix()}), false},
+		{"nbf within skew", signTenantJWT(t, key, "RS256", map[string]interface{}{"account": "a", "exp": exp, "nbf": now.Add(10 * time.Second).Unix()}), true},
+		{"alg mismatch", signTenantJWT(t, key, "ES256", map[string]interface{}{"account": "a", "exp": exp}), false},
+		{"alg none", encodeJWTPart(t, map[string]string{"alg": "none"}) + "." + encodeJWTPart(t, map[string]interface{}{"account": "a", "exp": exp}) + ".", false},
+		{"tampered claims", func() string {
+			token := signTenantJWT(t, key, "RS256", map[string]interface{}{"account": "a", "exp": exp})
+			parts := strings.Split(token, ".")
+			return parts[0] + "." + encodeJWTPart(t, map[string]interface{}{"account": "b", "exp": exp}) + "." + parts[2]
+		}(), false},
+		// HS256 signed with the public key as the secret, the classic confusion attack
+		{"hmac with public key", func() string {
+			der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+			if err != nil {
+				t.Fatalf("MarshalPKIXPublicKey: %v", err)
+			}
+			input := encodeJWTPart(t, map[string]string{"alg": "HS256"}) + "." + encodeJWTPart(t, map[string]interface{}{"account": "a", "exp": exp})
+			mac := hmac.New(sha256.New, der)
+			mac.Write([]byte(input))
+			return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+		}(), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := verifyTenantJWT(tc.token, &key.PublicKey, now)
+			if tc.wantOK && err != nil {
+				t.Fatalf("verifyTenantJWT: %v", err)
+			}
+			if !tc.wantOK && err == nil {
+				t.Fatalf("verifyTenantJWT accepted the token with claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestVerifyTenantJWTRejectsRSATokenForECDSAKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	now := time.Now()
+	token := signTenantJWT(t, rsaKey, "RS256", map[string]interface{}{"account": "a", "exp": now.Add(time.Hour).Unix()})
+	if _, err := verifyTenantJWT(token, &ecKey.PublicKey, now); err == nil {
+		t.Fatal("verifyTenantJWT accepted an RS256 token for an ECDSA key")
+	}
+}
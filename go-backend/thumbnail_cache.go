@@ -0,0 +1,219 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Thumbnail is a fetched thumbnail image
+type Thumbnail struct {
+	Data        []byte
+	ContentType string
+}
+
+// thumbnailStatusError reports Cloudflare answering a thumbnail request with a non-200 status
+type thumbnailStatusError struct {
+	status int
+}
+
+func (e thumbnailStatusError) Error() string {
+	return fmt.Sprintf("thumbnail request returned status %d", e.status)
+}
+
+// fetchThumbnail downloads a thumbnail image from its (possibly signed) URL
+func fetchThumbnail(ctx context.Context, thumbnailURL string) (*Thumbnail, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", thumbnailURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, thumbnailStatusError{status: resp.StatusCode}
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Thumbnail{Data: data, ContentType: resp.Header.Get("Content-Type")}, nil
+}
+
+// thumbnailCacheKey identifies one rendering of a video's thumbnail
+func thumbnailCacheKey(accountID, uid string, query url.Values) string {
+	return accountID + "/" + uid + "?" + query.Encode()
+}
+
+// ThumbnailCache holds proxied thumbnails so repeat requests skip Cloudflare.
+// Like the status cache it is bounded and evicts the least recently used entry.
+type ThumbnailCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cachedThumbnail struct {
+	key       string
+	value     *Thumbnail
+	expiresAt time.Time
+}
+
+func newThumbnailCache(ttl time.Duration, max int) *ThumbnailCache {
+	if max < 1 {
+		max = 1
+	}
+	return &ThumbnailCache{
+		ttl:     ttl,
+		max:     max,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached thumbnail for key if it hasn't expired
+func (t *ThumbnailCache) Get(key string) (*Thumbnail, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	elem, ok := t.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cachedThumbnail)
+	if time.Now().After(entry.expiresAt) {
+		t.order.Remove(elem)
+		delete(t.entries, key)
+		return nil, false
+	}
+	t.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Put stores a thumbnail for the cache's TTL, evicting the oldest entry when full
+func (t *ThumbnailCache) Put(key string, value *Thumbnail) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	expiresAt := time.Now().Add(t.ttl)
+	if elem, ok := t.entries[key]; ok {
+		entry := elem.Value.(*cachedThumbnail)
+		entry.value, entry.expiresAt = value, expiresAt
+		t.order.MoveToFront(elem)
+		return
+	}
+
+	t.entries[key] = t.order.PushFront(&cachedThumbnail{key: key, value: value, expiresAt: expiresAt})
+	if t.order.Len() > t.max {
+		oldest := t.order.Back()
+		t.order.Remove(oldest)
+		delete(t.entries, oldest.Value.(*cachedThumbnail).key)
+	}
+}
+
+// defaultPrewarmTimes are the timestamps warmed when THUMBNAIL_PREWARM_TIMES is unset
+const defaultPrewarmTimes = "0s,2s,5s"
+
+// prewarmTimeout bounds how long warming one video's thumbnails may take
+const prewarmTimeout = 30 * time.Second
+
+// ThumbnailPrewarmer fetches a video's thumbnails at fixed timestamps once it is
+// ready, so the first page showing them is served from the cache
+type ThumbnailPrewarmer struct {
+	config CloudflareConfig
+	cache  *ThumbnailCache
+	times  []string
+
+	mu     sync.Mutex
+	warmed map[string]time.Time
+}
+
+// parsePrewarmTimes parses a comma-separated list of thumbnail timestamps such as "0s,2s,5s"
+func parsePrewarmTimes(spec string) ([]string, error) {
+	var times []string
+	for _, t := range strings.Split(spec, ",") {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(t); err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid timestamp %q, expected a duration such as 2s", t)
+		}
+		times = append(times, t)
+	}
+	if len(times) == 0 {
+		return nil, errors.New("no timestamps listed")
+	}
+	return times, nil
+}
+
+func newThumbnailPrewarmer(config CloudflareConfig, cache *ThumbnailCache, times []string) *ThumbnailPrewarmer {
+	return &ThumbnailPrewarmer{
+		config: config,
+		cache:  cache,
+		times:  times,
+		warmed: make(map[string]time.Time),
+	}
+}
+
+// Trigger warms the thumbnails of a ready video in the background. A video is
+// warmed at most once per cache TTL however often webhooks and polls report it.
+func (p *ThumbnailPrewarmer) Trigger(ctx context.Context, video CloudflareResult) {
+	if !video.ReadyToStream {
+		return
+	}
+	account := accountConfig(ctx, p.config)
+	key := account.AccountID + "/" + video.UID
+
+	p.mu.Lock()
+	now := time.Now()
+	for k, at := range p.warmed {
+		if now.Sub(at) > p.cache.ttl {
+			delete(p.warmed, k)
+		}
+	}
+	if _, ok := p.warmed[key]; ok {
+		p.mu.Unlock()
+		return
+	}
+	p.warmed[key] = now
+	p.mu.Unlock()
+
+	go p.warm(withAccount(context.Background(), account), account.AccountID, video)
+}
+
+// warm fetches and caches the video's thumbnail at each configured timestamp
+func (p *ThumbnailPrewarmer) warm(ctx context.Context, accountID string, video CloudflareResult) {
+	ctx, cancel := context.WithTimeout(ctx, prewarmTimeout)
+	defer cancel()
+
+	urls, err := playbackURLs(ctx, p.config, video)
+	if err != nil {
+		fmt.Printf("Could not prewarm thumbnails for %s: %v\n", video.UID, err)
+		return
+	}
+
+	warmed := 0
+	for _, t := range p.times {
+		query := url.Values{"time": {t}}
+		thumbnail, err := fetchThumbnail(ctx, urls.Thumbnail+"?"+query.Encode())
+		if err != nil {
+			fmt.Printf("Could not prewarm thumbnail for %s at %s: %v\n", video.UID, t, err)
+			continue
+		}
+		p.cache.Put(thumbnailCacheKey(accountID, video.UID, query), thumbnail)
+		warmed++
+	}
+	fmt.Printf("Prewarmed %d/%d thumbnails for %s\n", warmed, len(p.times), video.UID)
+}
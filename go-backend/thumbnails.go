@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// thumbnailFits are the resize modes Cloudflare's thumbnail endpoint understands
+var thumbnailFits = map[string]bool{
+	"crop":       true,
+	"clamp":      true,
+	"fill":       true,
+	"scale-down": true,
+}
+
+// thumbnailURLTTL is how long a signed thumbnail URL handed to a client stays valid
+const thumbnailURLTTL = 5 * time.Minute
+
+// maxThumbnailDimension bounds requested thumbnail width and height in pixels
+const maxThumbnailDimension = 2000
+
+// thumbnailQuery validates the thumbnail options on the request and returns them
+// as the query string Cloudflare expects
+func thumbnailQuery(c *fiber.Ctx) (url.Values, error) {
+	query := url.Values{}
+
+	if t := c.Query("time"); t != "" {
+		if d, err := time.ParseDuration(t); err != nil || d < 0 {
+			return nil, fmt.Errorf("time must be a non-negative duration such as 1s or 1m30s")
+		}
+		query.Set("time", t)
+	}
+
+	for _, dim := range []string{"width", "height"} {
+		raw := c.Query(dim)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxThumbnailDimension {
+			return nil, fmt.Errorf("%s must be between 1 and %d", dim, maxThumbnailDimension)
+		}
+		query.Set(dim, raw)
+	}
+
+	if fit := c.Query("fit"); fit != "" {
+		if !thumbnailFits[fit] {
+			return nil, fmt.Errorf("fit must be one of crop, clamp, fill, scale-down")
+		}
+		query.Set("fit", fit)
+	}
+
+	if raw := c.Query("quality"); raw != "" {
+		q, err := strconv.Atoi(raw)
+		if err != nil || q < 1 || q > 100 {
+			return nil, fmt.Errorf("quality must be between 1 and 100")
+		}
+		query.Set("quality", raw)
+	}
+
+	return query, nil
+}
+
+func registerThumbnailRoutes(app *fiber.App, config CloudflareConfig, cache *ThumbnailCache, timeout fiber.Handler) {
+	// Proxy a thumbnail frame, sized and compressed as requested. Frames are cached,
+	// including any the prewarmer fetched when the video became ready.
+	app.Get("/api/video/:uid/thumbnail", timeout, func(c *fiber.Ctx) error {
+		query, err := thumbnailQuery(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid thumbnail options",
+				"details": err.Error(),
+			})
+		}
+
+		cacheKey := thumbnailCacheKey(accountConfig(c.UserContext(), config).AccountID, c.Params("uid"), query)
+		if thumbnail, ok := cache.Get(cacheKey); ok {
+			c.Set("Content-Type", thumbnail.ContentType)
+			c.Set("Cache-Control", "public, max-age=300")
+			return c.Send(thumbnail.Data)
+		}
+
+		video, err := fetchVideo(c.UserContext(), config, c.Params("uid"))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !video.Success {
+			return respondCloudflareError(c, "Failed to get video", video.Errors)
+		}
+
+		urls, err := playbackURLs(c.UserContext(), config, video.Result)
+		if err != nil {
+			return respondSigningError(c, 502, "Could not build thumbnail URL", err)
+		}
+
+		thumbnailURL := urls.Thumbnail
+		if len(query) > 0 {
+			thumbnailURL += "?" + query.Encode()
+		}
+
+		thumbnail, err := fetchThumbnail(c.UserContext(), thumbnailURL)
+		var statusErr thumbnailStatusError
+		if errors.As(err, &statusErr) {
+			return c.Status(502).JSON(fiber.Map{
+				"error":  "Cloudflare returned an error for the thumbnail",
+				"status": statusErr.status,
+			})
+		}
+		if err != nil {
+			return c.Status(502).JSON(fiber.Map{
+				"error":   "Failed to fetch thumbnail",
+				"details": err.Error(),
+			})
+		}
+		cache.Put(cacheKey, thumbnail)
+
+		c.Set("Content-Type", thumbnail.ContentType)
+		c.Set("Cache-Control", "public, max-age=300")
+		return c.Send(thumbnail.Data)
+	})
+
+	// A thumbnail URL for <img> tags, signed with a short-lived token for private videos
+	app.Get("/api/video/:uid/thumbnail-url", timeout, func(c *fiber.Ctx) error {
+		query, err := thumbnailQuery(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Invalid thumbnail options",
+				"details": err.Error(),
+			})
+		}
+
+		video, err := fetchVideo(c.UserContext(), config, c.Params("uid"))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video",
+				"details": err.Error(),
+			})
+		}
+		if !video.Success {
+			return respondCloudflareError(c, "Failed to get video", video.Errors)
+		}
+
+		base, err := customerBaseURL(video.Result)
+		if err != nil {
+			return c.Status(502).JSON(fiber.Map{
+				"error":   "Could not determine playback domain",
+				"details": err.Error(),
+			})
+		}
+
+		response := fiber.Map{"signed": false}
+		id := video.Result.UID
+		if video.Result.RequireSignedURLs {
+			if !c.QueryBool("signed", false) {
+				return c.Status(400).JSON(fiber.Map{
+					"error": "Video requires signed URLs, request one with signed=true",
+				})
+			}
+
+			expiresAt := time.Now().Add(thumbnailURLTTL)
+			token, err := createToken(c.UserContext(), config, id, map[string]interface{}{
+				"exp": expiresAt.Unix(),
+			})
+			if err != nil {
+				return respondSigningError(c, 500, "Failed to create token", err)
+			}
+			if !token.Success {
+				return respondCloudflareError(c, "Token creation failed", token.Errors)
+			}
+			id = token.Result.Token
+			response["signed"] = true
+			response["expiresAt"] = expiresAt.UTC().Format(time.RFC3339)
+		}
+
+		thumbnailURL := newPlaybackURLs(base, id).Thumbnail
+		if len(query) > 0 {
+			thumbnailURL += "?" + query.Encode()
+		}
+		response["url"] = thumbnailURL
+
+		return respond(c, 200, response)
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSFiles are the certificate and key the server terminates TLS with
+type TLSFiles struct {
+	CertFile string
+	KeyFile  string
+}
+
+// loadTLSFiles reads TLS_CERT_FILE and TLS_KEY_FILE and checks they hold a
+// matching certificate and key. It returns nil when neither is set, in which
+// case the server speaks plain HTTP.
+func loadTLSFiles() (*TLSFiles, error) {
+	files := &TLSFiles{
+		CertFile: os.Getenv("TLS_CERT_FILE"),
+		KeyFile:  os.Getenv("TLS_KEY_FILE"),
+	}
+	if files.CertFile == "" && files.KeyFile == "" {
+		return nil, nil
+	}
+	if files.CertFile == "" || files.KeyFile == "" {
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	cert, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load %s and %s: %w", files.CertFile, files.KeyFile, err)
+	}
+	if len(cert.Certificate) == 0 {
+		return nil, fmt.Errorf("%s holds no certificate", files.CertFile)
+	}
+	return files, nil
+}
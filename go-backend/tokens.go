@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TokenRequest is the body accepted by the token endpoint. Times are Unix seconds.
+// With CreatorScope the token is only issued if the video belongs to the creator
+// named in the caller's account JWT; callers whose JWT names a creator are
+// always scoped.
+type TokenRequest struct {
+	Exp          int64        `json:"exp" validate:"gte=0"`
+	Nbf          int64        `json:"nbf" validate:"gte=0"`
+	CreatorScope bool         `json:"creatorScope"`
+	AccessRules  []AccessRule `json:"accessRules" validate:"max=10,dive"`
+}
+
+// AccessRule restricts where a token can be used, evaluated in order by Cloudflare
+type AccessRule struct {
+	Type    string   `json:"type" validate:"oneof=any ip.src ip.geoip.country"`
+	Action  string   `json:"action" validate:"oneof=allow block"`
+	Country []string `json:"country,omitempty" validate:"omitempty,dive,len=2"`
+	IP      []string `json:"ip,omitempty" validate:"omitempty,dive,cidr|ip"`
+}
+
+// maxAccessRules is how many access rules Cloudflare accepts on one token
+const maxAccessRules = 10
+
+// loadTokenAccessRules reads TOKEN_ACCESS_RULES, a JSON array of access rules
+// applied to every token the token endpoint issues. Unset means no default rules.
+func loadTokenAccessRules() ([]AccessRule, error) {
+	raw := os.Getenv("TOKEN_ACCESS_RULES")
+	if raw == "" {
+		return nil, nil
+	}
+
+	var rules []AccessRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, fmt.Errorf("TOKEN_ACCESS_RULES must be a JSON array of access rules: %w", err)
+	}
+	rules = normalizeAccessRules(rules)
+	if err := validate.Var(rules, fmt.Sprintf("max=%d,dive", maxAccessRules)); err != nil {
+		return nil, fmt.Errorf("TOKEN_ACCESS_RULES: %w", err)
+	}
+	return rules, nil
+}
+
+// mergeAccessRules puts the default rules ahead of a request's own. Cloudflare
+// stops at the first matching rule, so defaults always take precedence.
+func mergeAccessRules(defaults, additions []AccessRule) []AccessRule {
+	if len(defaults) == 0 {
+		return additions
+	}
+	return append(append([]AccessRule{}, defaults...), additions...)
+}
+
+// TokenResponse represents Cloudflare's response when creating a signed playback token
+type TokenResponse struct {
+	Result struct {
+		Token string `json:"token"`
+	} `json:"result"`
+	Success  bool              `json:"success"`
+	Errors   []CloudflareError `json:"errors"`
+	Messages []string          `json:"messages"`
+}
+
+// createToken asks Cloudflare to sign a playback token for the video with the given
+// claims, using the account's active signing key. It returns errSigningNotConfigured
+// without calling Cloudflare when the account has no signing key.
+func createToken(ctx context.Context, config CloudflareConfig, uid string, claims map[string]interface{}) (*TokenResponse, error) {
+	account := accountConfig(ctx, config)
+	if !signingConfigured(account) {
+		return nil, errSigningNotConfigured
+	}
+	claims["id"] = account.SigningKeyID
+	claims["pem"] = account.SigningKeyPEM
+	return callCloudflare[TokenResponse](ctx, config, "POST", "/stream/"+uid+"/token", claims)
+}
+
+func registerTokenRoutes(app *fiber.App, config CloudflareConfig, defaultTTL time.Duration, defaultRules []AccessRule, timeout fiber.Handler) {
+	// Create a signed playback token valid between nbf and exp. With local=true the
+	// token is signed here with the account's signing key instead of by Cloudflare.
+	// Any default access rules come first, followed by the request's own.
+	app.Post("/api/video/:uid/token", timeout, requireJSON(), func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		var body TokenRequest
+		if len(c.Body()) > 0 {
+			if ok, err := bindBody(c, &body); !ok {
+				return err
+			}
+		}
+
+		now := time.Now()
+		if body.Exp == 0 {
+			base := now
+			if body.Nbf > 0 {
+				base = time.Unix(body.Nbf, 0)
+			}
+			body.Exp = base.Add(defaultTTL).Unix()
+		}
+		if body.Exp <= now.Unix() {
+			return respondFieldErrors(c, []FieldError{{Field: "exp", Error: "must be in the future"}})
+		}
+		if body.Nbf != 0 && body.Nbf >= body.Exp {
+			return respondFieldErrors(c, []FieldError{{Field: "nbf", Error: "must be before exp"}})
+		}
+		accessRules := mergeAccessRules(defaultRules, body.AccessRules)
+		if len(accessRules) > maxAccessRules {
+			return respondFieldErrors(c, []FieldError{{
+				Field: "accessRules",
+				Error: fmt.Sprintf("at most %d rules including the %d defaults", maxAccessRules, len(defaultRules)),
+			}})
+		}
+
+		// Scope the token to the authenticated creator by refusing to sign for
+		// anyone else's content. The creator comes from the caller's JWT, never
+		// from the body, so it can't be claimed by whoever asks.
+		creator := requestCreator(c.UserContext())
+		if body.CreatorScope && creator == "" {
+			return c.Status(403).JSON(fiber.Map{
+				"error":   "Creator scope requires an authenticated creator",
+				"details": "the account token names no creator",
+			})
+		}
+		if creator != "" {
+			if err := validateCreator(creator); err != nil {
+				return c.Status(403).JSON(fiber.Map{
+					"error":   "Invalid creator",
+					"details": err.Error(),
+				})
+			}
+
+			video, err := fetchVideo(c.UserContext(), config, uid)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Failed to get video",
+					"details": err.Error(),
+				})
+			}
+			if !video.Success {
+				return respondCloudflareError(c, "Failed to get video", video.Errors)
+			}
+			if video.Result.Creator != creator {
+				return c.Status(403).JSON(fiber.Map{
+					"error": "Video does not belong to this creator",
+				})
+			}
+		}
+
+		var token string
+		local := c.QueryBool("local", false)
+		if local {
+			account := accountConfig(c.UserContext(), config)
+			if !signingConfigured(account) {
+				return c.Status(501).JSON(fiber.Map{
+					"error":   "Signing not configured",
+					"details": "local signing needs a signing key ID and PEM",
+				})
+			}
+			key, err := parseSigningKey(account.SigningKeyPEM)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Failed to load signing key",
+					"details": err.Error(),
+				})
+			}
+
+			token, err = signStreamToken(key, StreamTokenClaims{
+				Subject:     uid,
+				KeyID:       account.SigningKeyID,
+				ExpiresAt:   body.Exp,
+				NotBefore:   body.Nbf,
+				AccessRules: accessRules,
+			})
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Failed to sign token",
+					"details": err.Error(),
+				})
+			}
+		} else {
+			payload := fiber.Map{"exp": body.Exp}
+			if body.Nbf != 0 {
+				payload["nbf"] = body.Nbf
+			}
+			if len(accessRules) > 0 {
+				payload["accessRules"] = accessRules
+			}
+
+			result, err := createToken(c.UserContext(), config, uid, payload)
+			if err != nil {
+				return respondSigningError(c, 500, "Failed to create token", err)
+			}
+
+			if !result.Success {
+				return respondCloudflareError(c, "Token creation failed", result.Errors)
+			}
+			token = result.Result.Token
+		}
+
+		response := fiber.Map{
+			"token": token,
+			"exp":   body.Exp,
+			"local": local,
+		}
+		if body.Nbf != 0 {
+			response["nbf"] = body.Nbf
+		}
+		if creator != "" {
+			response["creator"] = creator
+		}
+		if keyID := accountConfig(c.UserContext(), config).SigningKeyID; keyID != "" {
+			response["keyId"] = keyID
+		}
+		return respond(c, 200, response)
+	})
+}
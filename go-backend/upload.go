@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newMultipartUploadBody streams src to the returned reader as the "file" part
+// of a multipart form. The writer runs in its own goroutine and reports its final
+// error on the returned channel exactly once. Closing the reader always lets the
+// goroutine finish, so callers must close it on every path.
+func newMultipartUploadBody(src io.Reader, filename string) (*io.PipeReader, string, <-chan error) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	done := make(chan error, 1)
+
+	go func() {
+		err := writeMultipartFile(writer, src, filename)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	return pr, writer.FormDataContentType(), done
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// uploadSizeMismatch describes why an upload looks truncated, or returns "" when
+// the streamed byte count and Cloudflare's reported size agree with the file size.
+// Cloudflare's size is only checked when it reports one.
+func uploadSizeMismatch(expected, streamed, reported int64) string {
+	if expected <= 0 {
+		return ""
+	}
+	if streamed != expected {
+		return fmt.Sprintf("streamed %d of %d bytes", streamed, expected)
+	}
+	if reported > 0 && reported != expected {
+		return fmt.Sprintf("Cloudflare received %d of %d bytes", reported, expected)
+	}
+	return ""
+}
+
+// writeMultipartFile copies src into a single form file part and terminates the form
+func writeMultipartFile(writer *multipart.Writer, src io.Reader, filename string) error {
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return fmt.Errorf("could not create form file: %w", err)
+	}
+
+	if _, err := io.Copy(part, src); err != nil {
+		return fmt.Errorf("could not copy file content: %w", err)
+	}
+
+	return writer.Close()
+}
+
+// UploadFailure is a streamed upload that never produced a Cloudflare answer,
+// with the status and message the client should get for it
+type UploadFailure struct {
+	Status   int
+	Message  string
+	Err      error
+	Response []byte
+}
+
+func (f *UploadFailure) Error() string {
+	return fmt.Sprintf("%s: %v", f.Message, f.Err)
+}
+
+// respondUploadFailure answers with the failure's status, including Cloudflare's
+// raw response when it could not be parsed
+func respondUploadFailure(c *fiber.Ctx, failure *UploadFailure) error {
+	body := fiber.Map{
+		"error":   failure.Message,
+		"details": failure.Err.Error(),
+	}
+	if failure.Response != nil {
+		body["response"] = string(failure.Response)
+	}
+	return c.Status(failure.Status).JSON(body)
+}
+
+// streamUpload sends src to Cloudflare as a multipart upload without buffering
+// it, returning the parsed response, its raw body and how many bytes of src
+// were read. The writer goroutine has always exited by the time it returns.
+func streamUpload(ctx context.Context, config CloudflareConfig, src io.Reader, filename string, size int64) (*VideoUploadResponse, []byte, int64, *UploadFailure) {
+	streamed := &countingReader{r: src}
+	body, contentType, writeDone := newMultipartUploadBody(streamed, filename)
+	// Closing the pipe unblocks the writer; waiting on it makes streamed.n safe to read
+	finish := func() error {
+		body.Close()
+		if err := <-writeDone; err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			return err
+		}
+		return nil
+	}
+
+	// Create Cloudflare Stream upload request
+	req, err := newCloudflareRequest(ctx, config, "POST", "/stream", body)
+	if err != nil {
+		finish()
+		fmt.Printf("Request creation error: %v\n", err)
+		return nil, nil, streamed.n, &UploadFailure{Status: 500, Message: "Could not create request", Err: err}
+	}
+	fmt.Printf("Making request to: %s\n", req.URL)
+
+	// Set headers
+	req.Header.Set("Content-Type", contentType)
+
+	// Declare the length when the file size is known; otherwise fall back to chunked transfer
+	req.ContentLength = multipartContentLength(contentType, filename, size)
+	if req.ContentLength < 0 {
+		fmt.Printf("Unknown size for %s, streaming with chunked transfer\n", filename)
+	}
+
+	// Send request to Cloudflare
+	resp, err := httpClient.Do(req)
+	// Cloudflare may answer before consuming the whole body; stop the writer either way
+	writeErr := finish()
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if writeErr != nil {
+		fmt.Printf("Multipart write error: %v\n", writeErr)
+		return nil, nil, streamed.n, &UploadFailure{Status: 500, Message: "Could not copy file content", Err: writeErr}
+	}
+	if err != nil {
+		fmt.Printf("Cloudflare request error: %v\n", err)
+		return nil, nil, streamed.n, &UploadFailure{Status: 500, Message: "Failed to upload to Cloudflare", Err: err}
+	}
+
+	// Read response body
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Printf("Error reading response body: %v\n", err)
+		return nil, nil, streamed.n, &UploadFailure{Status: 500, Message: "Could not read response", Err: err}
+	}
+	fmt.Printf("Cloudflare Response Status: %d\n", resp.StatusCode)
+	fmt.Printf("Cloudflare Response Body: %s\n", string(data))
+
+	// Parse response
+	var result VideoUploadResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		fmt.Printf("JSON parse error: %v\n", err)
+		return nil, data, streamed.n, &UploadFailure{Status: 500, Message: "Could not parse response", Err: err, Response: data}
+	}
+	return &result, data, streamed.n, nil
+}
+
+// multipartContentLength predicts the exact size of the body produced by
+// newMultipartUploadBody for a file of the given size, so the upload can be sent
+// with a Content-Length. It returns -1 when the size is unknown (zero or negative),
+// in which case the request goes out with chunked transfer encoding instead.
+// Clients streaming without a known length therefore still work; Cloudflare's
+// basic upload accepts chunked bodies, and a mismatch against a declared length
+// makes the transport fail the request rather than send a truncated file.
+func multipartContentLength(contentType, filename string, size int64) int64 {
+	if size <= 0 {
+		return -1
+	}
+
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params["boundary"] == "" {
+		return -1
+	}
+
+	// Render the form around an empty file to measure the framing overhead
+	overhead := &bytes.Buffer{}
+	writer := multipart.NewWriter(overhead)
+	if err := writer.SetBoundary(params["boundary"]); err != nil {
+		return -1
+	}
+	if _, err := writer.CreateFormFile("file", filename); err != nil {
+		return -1
+	}
+	if err := writer.Close(); err != nil {
+		return -1
+	}
+
+	return int64(overhead.Len()) + size
+}
+
+// UploadOptions are the per-request settings applied to a video once it is uploaded
+type UploadOptions struct {
+	Priority      string
+	RequireSigned bool
+	Labels        []string
+}
+
+// finalizeUpload tags a freshly uploaded video with the priority it was processed
+// at and its labels, and applies its signing choice, updating result to match on success
+func finalizeUpload(ctx context.Context, config CloudflareConfig, result *VideoUploadResponse, opts UploadOptions) error {
+	meta := videoMeta(result)
+	meta["priority"] = opts.Priority
+	if len(opts.Labels) > 0 {
+		meta["labels"] = joinLabels(opts.Labels)
+	}
+	updated, err := updateVideo(ctx, config, result.Result.UID, map[string]interface{}{
+		"uid":               result.Result.UID,
+		"meta":              meta,
+		"requireSignedURLs": opts.RequireSigned,
+	})
+	if err != nil {
+		return err
+	}
+	if !updated.Success {
+		return fmt.Errorf("update failed: %v", updated.Errors)
+	}
+	result.Result.RequireSignedURLs = opts.RequireSigned
+	result.Result.Meta.Labels = joinLabels(opts.Labels)
+	return nil
+}
+
+// uploadFromSpool copies the file to a private temp file (mode 0600) and uploads
+// it from there. Network errors and Cloudflare 429/5xx answers are retried up to
+// attempts times from the spooled copy. The temp file is always removed. A final
+// Cloudflare refusal is returned as the response rather than as an error. When
+// tee is set the file is written to it once, while it is spooled.
+func uploadFromSpool(ctx context.Context, config CloudflareConfig, file *multipart.FileHeader, attempts int, tee io.Writer) (*VideoUploadResponse, int64, error) {
+	if attempts < 1 {
+		attempts = 1
+	}
+	path, err := spoolUpload(file, tee)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not spool upload: %w", err)
+	}
+	defer os.Remove(path)
+
+	var result *VideoUploadResponse
+	var streamed int64
+	for attempt := 1; attempt <= attempts; attempt++ {
+		result, streamed, err = uploadSpooledFile(ctx, config, path, file.Filename, file.Size)
+		if err == nil {
+			status := cloudflareErrorStatus(result.Errors)
+			if result.Success || (status != 429 && status < 500) {
+				return result, streamed, nil
+			}
+		}
+		if attempt == attempts {
+			break
+		}
+		fmt.Printf("Spooled upload of %s attempt %d failed, retrying\n", file.Filename, attempt)
+		if !sleepContext(ctx, time.Duration(attempt)*time.Second) {
+			return nil, streamed, ctx.Err()
+		}
+	}
+	return result, streamed, err
+}
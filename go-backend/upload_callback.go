@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// uploadCallbackEvent names the only event sent to UPLOAD_CALLBACK_URL so far
+const uploadCallbackEvent = "upload.completed"
+
+// uploadCallbackSignatureHeader carries the callback signature, in the same format
+// Cloudflare uses for its own webhooks: "time=<unix>,sig1=<hex>", where sig1 is
+// the HMAC-SHA256 of "<unix>.<body>" keyed with UPLOAD_CALLBACK_SECRET. Receivers
+// should recompute it over the raw body and reject stale timestamps.
+const uploadCallbackSignatureHeader = "X-Upload-Signature"
+
+// UploadCallbackPayload is the JSON body POSTed to UPLOAD_CALLBACK_URL after an
+// upload succeeds. Source is "sync" or "queued"; meta is the video's meta as
+// Cloudflare returned it.
+type UploadCallbackPayload struct {
+	Event             string                 `json:"event"`
+	UID               string                 `json:"uid"`
+	Source            string                 `json:"source"`
+	Filename          string                 `json:"filename"`
+	Size              int64                  `json:"size"`
+	RequireSignedURLs bool                   `json:"requireSignedURLs"`
+	Meta              map[string]interface{} `json:"meta"`
+	Created           time.Time              `json:"created"`
+	SentAt            time.Time              `json:"sentAt"`
+}
+
+// UploadCallbackConfig tunes delivery of upload callbacks
+type UploadCallbackConfig struct {
+	URL         string
+	Secret      string
+	MaxAttempts int
+	RetryDelay  time.Duration
+	Timeout     time.Duration
+}
+
+// UploadCallback notifies the application's own system of finished uploads. A
+// nil *UploadCallback sends nothing.
+type UploadCallback struct {
+	cfg UploadCallbackConfig
+	// Kept apart from httpClient so a failing receiver can't trip the Cloudflare
+	// circuit breaker or use up the Cloudflare rate limit
+	client *http.Client
+}
+
+func newUploadCallback(cfg UploadCallbackConfig) *UploadCallback {
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	return &UploadCallback{
+		cfg:    cfg,
+		client: &http.Client{Transport: tracingTransport{next: http.DefaultTransport}},
+	}
+}
+
+// signUploadCallback returns the signature header value for body sent at t
+func signUploadCallback(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "time=" + timestamp + ",sig1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notify sends the callback for an uploaded video in the background, so the
+// upload response never waits on it
+func (u *UploadCallback) Notify(video *VideoUploadResponse, source, filename string) {
+	if u == nil {
+		return
+	}
+
+	payload := UploadCallbackPayload{
+		Event:             uploadCallbackEvent,
+		UID:               video.Result.UID,
+		Source:            source,
+		Filename:          filename,
+		Size:              video.Result.Size,
+		RequireSignedURLs: video.Result.RequireSignedURLs,
+		Meta:              videoMeta(video),
+		Created:           video.Result.Created,
+	}
+	go u.deliver(payload)
+}
+
+// deliver POSTs the payload, retrying network errors, 429s and 5xx responses
+// with a doubling delay up to MaxAttempts in all
+func (u *UploadCallback) deliver(payload UploadCallbackPayload) {
+	delay := u.cfg.RetryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := u.send(payload)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= u.cfg.MaxAttempts {
+			fmt.Printf("Upload callback for %s failed after %d attempt(s): %v\n", payload.UID, attempt, err)
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// send makes one delivery attempt and reports whether a failure is worth retrying.
+// Each attempt is signed afresh so its timestamp is current.
+func (u *UploadCallback) send(payload UploadCallbackPayload) (bool, error) {
+	payload.SentAt = time.Now().UTC()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), u.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", u.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if u.cfg.Secret != "" {
+		req.Header.Set(uploadCallbackSignatureHeader, signUploadCallback(u.cfg.Secret, payload.SentAt, body))
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode == 429 || resp.StatusCode >= 500, fmt.Errorf("callback returned HTTP %d", resp.StatusCode)
+	}
+	return false, nil
+}
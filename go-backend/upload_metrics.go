@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// uploadDurationBuckets are the upper bounds, in seconds, of the upload duration histogram
+var uploadDurationBuckets = []float64{1, 5, 15, 60, 300, 900}
+
+// UploadTiming measures one upload. Upload covers only sending the file to
+// Cloudflare and reading its answer; Total is the whole handler or job attempt.
+type UploadTiming struct {
+	Bytes  int64
+	Upload time.Duration
+	Total  time.Duration
+}
+
+// Throughput is the upload rate in megabytes (10^6 bytes) per second
+func (t UploadTiming) Throughput() float64 {
+	if t.Upload <= 0 {
+		return 0
+	}
+	return float64(t.Bytes) / 1e6 / t.Upload.Seconds()
+}
+
+// UploadLogEntry is the structured log line written for every upload
+type UploadLogEntry struct {
+	Time           string  `json:"time"`
+	Event          string  `json:"event"`
+	Source         string  `json:"source"`
+	Filename       string  `json:"filename"`
+	UID            string  `json:"uid,omitempty"`
+	Success        bool    `json:"success"`
+	Bytes          int64   `json:"bytes"`
+	UploadMS       float64 `json:"uploadMs"`
+	TotalMS        float64 `json:"totalMs"`
+	ThroughputMBps float64 `json:"throughputMBps"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// UploadMetrics aggregates upload timings since the server started
+type UploadMetrics struct {
+	mu            sync.Mutex
+	count         int64
+	failed        int64
+	bytes         int64
+	uploadSeconds float64
+	totalSeconds  float64
+	maxSeconds    float64
+	buckets       []int64
+}
+
+// uploadMetrics is shared by the upload handler and the upload queue
+var uploadMetrics = newUploadMetrics()
+
+func newUploadMetrics() *UploadMetrics {
+	return &UploadMetrics{buckets: make([]int64, len(uploadDurationBuckets))}
+}
+
+// Record adds one upload to the totals
+func (m *UploadMetrics) Record(timing UploadTiming, success bool) {
+	seconds := timing.Upload.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.count++
+	if !success {
+		m.failed++
+	}
+	m.bytes += timing.Bytes
+	m.uploadSeconds += seconds
+	m.totalSeconds += timing.Total.Seconds()
+	if seconds > m.maxSeconds {
+		m.maxSeconds = seconds
+	}
+	for i, bound := range uploadDurationBuckets {
+		if seconds <= bound {
+			m.buckets[i]++
+		}
+	}
+}
+
+// Snapshot returns the totals in a JSON-friendly shape. Buckets are cumulative,
+// keyed by their upper bound in seconds, with "+Inf" equal to the count.
+func (m *UploadMetrics) Snapshot() fiber.Map {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buckets := make(fiber.Map, len(uploadDurationBuckets)+1)
+	for i, bound := range uploadDurationBuckets {
+		buckets[fmt.Sprintf("%g", bound)] = m.buckets[i]
+	}
+	buckets["+Inf"] = m.count
+
+	var throughput float64
+	if m.uploadSeconds > 0 {
+		throughput = float64(m.bytes) / 1e6 / m.uploadSeconds
+	}
+	return fiber.Map{
+		"count":                 m.count,
+		"failed":                m.failed,
+		"bytes":                 m.bytes,
+		"uploadSecondsSum":      m.uploadSeconds,
+		"totalSecondsSum":       m.totalSeconds,
+		"uploadSecondsMax":      m.maxSeconds,
+		"uploadSecondsBuckets":  buckets,
+		"averageThroughputMBps": throughput,
+	}
+}
+
+// recordUpload logs one upload as a JSON line and adds it to the metrics
+func recordUpload(entry UploadLogEntry, timing UploadTiming) {
+	uploadMetrics.Record(timing, entry.Success)
+
+	entry.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	entry.Event = "upload"
+	entry.Bytes = timing.Bytes
+	entry.UploadMS = float64(timing.Upload.Microseconds()) / 1000
+	entry.TotalMS = float64(timing.Total.Microseconds()) / 1000
+	entry.ThroughputMBps = timing.Throughput()
+
+	line, _ := json.Marshal(entry)
+	fmt.Println(string(line))
+}
+
+func registerMetricsRoutes(app *fiber.App) {
+	// Upload durations, bytes and throughput since the server started
+	app.Get("/api/metrics/uploads", func(c *fiber.Ctx) error {
+		return respond(c, 200, uploadMetrics.Snapshot())
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingReader yields n bytes of data and then fails
+type failingReader struct {
+	n   int
+	err error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, r.err
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	for i := range p {
+		p[i] = 'x'
+	}
+	r.n -= len(p)
+	return len(p), nil
+}
+
+func TestStreamUploadReaderFailsMidCopy(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"success":true,"errors":[],"messages":[],"result":{"uid":"abc"}}`)
+	}))
+	config := CloudflareConfig{AccountID: "acc", APIToken: "token", BaseURL: server.URL}
+
+	readErr := errors.New("disk went away")
+	src := &failingReader{n: 64 << 10, err: readErr}
+	result, _, streamed, failure := streamUpload(context.Background(), config, src, "clip.mp4", 1<<20)
+	if failure == nil {
+		t.Fatalf("expected a failure, got result %+v", result)
+	}
+	if failure.Status != 500 {
+		t.Errorf("status = %d, want 500", failure.Status)
+	}
+	if !errors.Is(failure.Err, readErr) {
+		t.Errorf("err = %v, want it to wrap %v", failure.Err, readErr)
+	}
+	if streamed != 64<<10 {
+		t.Errorf("streamed = %d, want %d", streamed, 64<<10)
+	}
+
+	server.Close()
+	httpClient.CloseIdleConnections()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines = %d after the upload, %d before; the multipart writer leaked", after, before)
+	}
+}
+
+func TestStreamUploadUnparseableResponse(t *testing.T) {
+	config := newFakeCloudflare(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(502)
+		io.WriteString(w, "<html>bad gateway</html>")
+	}))
+
+	content := bytes.Repeat([]byte("v"), 1024)
+	_, _, streamed, failure := streamUpload(context.Background(), config, bytes.NewReader(content), "clip.mp4", int64(len(content)))
+	if failure == nil {
+		t.Fatal("expected a failure for a non-JSON response")
+	}
+	if failure.Message != "Could not parse response" || !strings.Contains(string(failure.Response), "bad gateway") {
+		t.Errorf("failure = %q with response %q", failure.Message, failure.Response)
+	}
+	if streamed != int64(len(content)) {
+		t.Errorf("streamed = %d, want %d", streamed, len(content))
+	}
+}
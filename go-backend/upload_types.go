@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// UploadTypePolicy decides which MIME types each API key may upload. A nil
+// list allows every type, so uploads are only restricted once a list is configured.
+type UploadTypePolicy struct {
+	Default []string
+	ByKey   map[string][]string
+}
+
+// splitTypes parses a sep-separated list of MIME types, lower-cased
+func splitTypes(spec, sep string) []string {
+	var types []string
+	for _, t := range strings.Split(spec, sep) {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// loadUploadTypePolicy builds the policy from a global list ("video/mp4,video/webm")
+// and per-key overrides ("key1=video/mp4,key2=video/mp4|video/webm"). Without a
+// global list, keys that have no override may upload any type.
+func loadUploadTypePolicy(defaults, byKey string) (UploadTypePolicy, error) {
+	policy := UploadTypePolicy{
+		Default: splitTypes(defaults, ","),
+		ByKey:   make(map[string][]string),
+	}
+
+	if byKey == "" {
+		return policy, nil
+	}
+	for _, entry := range strings.Split(byKey, ",") {
+		key, spec, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || key == "" {
+			return policy, fmt.Errorf("invalid entry %q, expected key=type|type", entry)
+		}
+		types := splitTypes(spec, "|")
+		if len(types) == 0 {
+			return policy, fmt.Errorf("no types listed for key %q", key)
+		}
+		policy.ByKey[key] = types
+	}
+	return policy, nil
+}
+
+// Allowed returns the MIME types the given API key may upload, or nil for any type
+func (p UploadTypePolicy) Allowed(apiKey string) []string {
+	if types, ok := p.ByKey[apiKey]; ok && apiKey != "" {
+		return types
+	}
+	return p.Default
+}
+
+// uploadMediaType is the file's declared media type, or one guessed from its
+// extension when the client sent none
+func uploadMediaType(file *multipart.FileHeader) string {
+	declared := file.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(declared); err == nil && mediaType != "application/octet-stream" {
+		return strings.ToLower(mediaType)
+	}
+	if guessed := mime.TypeByExtension(filepath.Ext(file.Filename)); guessed != "" {
+		mediaType, _, _ := mime.ParseMediaType(guessed)
+		return strings.ToLower(mediaType)
+	}
+	return "application/octet-stream"
+}
+
+// typeAllowed reports whether mediaType is in the allowed list
+func typeAllowed(mediaType string, allowed []string) bool {
+	for _, t := range allowed {
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// containerHeaderSize is how much of a file is read to recognise its container.
+// MPEG transport streams need two 188-byte packets to be told apart from noise.
+const containerHeaderSize = 512
+
+// detectContainer names the video container the header belongs to, or returns ""
+// for anything unrecognised. It checks magic bytes only, not that the file decodes.
+func detectContainer(header []byte) string {
+	switch {
+	case len(header) >= 8 && bytes.Equal(header[4:8], []byte("ftyp")):
+		return "mp4"
+	case len(header) >= 8 && isQuickTimeAtom(header[4:8]):
+		return "quicktime"
+	case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return "matroska"
+	case len(header) >= 12 && bytes.HasPrefix(header, []byte("RIFF")) && bytes.Equal(header[8:12], []byte("AVI ")):
+		return "avi"
+	case bytes.HasPrefix(header, []byte{0x00, 0x00, 0x01, 0xBA}):
+		return "mpeg-ps"
+	case len(header) > 188 && header[0] == 0x47 && header[188] == 0x47:
+		return "mpeg-ts"
+	case bytes.HasPrefix(header, []byte("FLV")):
+		return "flv"
+	}
+	return ""
+}
+
+// isQuickTimeAtom matches the top-level atoms older QuickTime files start with
+// instead of ftyp
+func isQuickTimeAtom(atom []byte) bool {
+	switch string(atom) {
+	case "moov", "mdat", "wide", "free", "skip":
+		return true
+	}
+	return false
+}
+
+// sniffContainer reads the start of an uploaded file and detects its container
+func sniffContainer(file *multipart.FileHeader) (string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	header := make([]byte, containerHeaderSize)
+	n, err := f.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return detectContainer(header[:n]), nil
+}
+
+// Upload validation modes, chosen with VALIDATION_MODE
+const (
+	// validateMIME checks only the declared (or guessed) media type, as always
+	validateMIME = "mime"
+	// validateExtension checks only the filename's extension
+	validateExtension = "extension"
+	// validateBoth requires the media type and the extension to be allowed
+	validateBoth = "both"
+	// validateEither accepts a file when either check passes
+	validateEither = "either"
+)
+
+// defaultUploadExtensions are the extensions accepted when ALLOWED_EXTENSIONS is unset
+var defaultUploadExtensions = []string{".mp4", ".mov", ".webm"}
+
+// loadValidationMode reads VALIDATION_MODE, defaulting to MIME checks only
+func loadValidationMode() (string, error) {
+	switch mode := strings.ToLower(os.Getenv("VALIDATION_MODE")); mode {
+	case "":
+		return validateMIME, nil
+	case validateMIME, validateExtension, validateBoth, validateEither:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("VALIDATION_MODE must be one of %s, %s, %s, %s, got %q",
+			validateMIME, validateExtension, validateBoth, validateEither, mode)
+	}
+}
+
+// splitExtensions parses a comma-separated list of extensions, lower-cased and
+// with a leading dot whether or not one was written
+func splitExtensions(spec string) []string {
+	var exts []string
+	for _, ext := range strings.Split(spec, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		exts = append(exts, ext)
+	}
+	return exts
+}
+
+// sanitizeFilename strips any client-supplied directories (either slash style)
+// and surrounding whitespace, leaving just the base name
+func sanitizeFilename(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = strings.TrimSpace(path.Base(name))
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// uploadExtension is the lower-cased extension of the file's sanitized name
+func uploadExtension(file *multipart.FileHeader) string {
+	return strings.ToLower(filepath.Ext(sanitizeFilename(file.Filename)))
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Lifecycle of a tracked direct creator upload
+const (
+	uploadPending = "pending"
+	uploadReady   = "ready"
+	uploadError   = "error"
+	uploadExpired = "expired"
+)
+
+// PendingUpload is a direct creator upload that hasn't finished processing
+type PendingUpload struct {
+	UID       string    `json:"uid"`
+	State     string    `json:"state"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// trackPendingUpload records a freshly created direct upload URL in an account
+func trackPendingUpload(store VideoStore, accountID, uid string, expiresAt time.Time) {
+	store.Update(uid, func(record VideoRecord, _ bool) (VideoRecord, bool) {
+		record.AccountID = accountID
+		record.UploadState = uploadPending
+		record.UploadCreatedAt = time.Now().UTC()
+		record.UploadExpiresAt = expiresAt
+		return record, true
+	})
+}
+
+// resolveUploadState moves a pending upload to ready or error once Cloudflare
+// reports it, returning the new state or "" if nothing changed
+func resolveUploadState(store VideoStore, video CloudflareResult) string {
+	var state string
+	switch {
+	case video.ReadyToStream:
+		state = uploadReady
+	case video.Status.State == "error":
+		state = uploadError
+	default:
+		return ""
+	}
+
+	resolved := ""
+	store.Update(video.UID, func(record VideoRecord, ok bool) (VideoRecord, bool) {
+		if !ok || record.UploadState != uploadPending {
+			return record, false
+		}
+		record.UploadState = state
+		resolved = state
+		return record, true
+	})
+	return resolved
+}
+
+// expirePendingUpload marks a still-pending upload expired, reporting whether it was
+func expirePendingUpload(store VideoStore, uid string) bool {
+	expired := false
+	store.Update(uid, func(record VideoRecord, ok bool) (VideoRecord, bool) {
+		if !ok || record.UploadState != uploadPending {
+			return record, false
+		}
+		record.UploadState = uploadExpired
+		expired = true
+		return record, true
+	})
+	return expired
+}
+
+// uploadNeverArrived reports whether Cloudflare shows no file for a direct
+// upload: the video is gone (not found) or still waiting for its upload
+func uploadNeverArrived(video *VideoUploadResponse) bool {
+	if !video.Success {
+		return cloudflareErrorStatus(video.Errors) == 404
+	}
+	return video.Result.Status.State == "pendingupload"
+}
+
+// recordAccount returns the account a record belongs to: one of accounts, keyed
+// by account ID, or config for records made before accounts were tracked
+func recordAccount(record VideoRecord, config CloudflareConfig, accounts map[string]CloudflareConfig) (CloudflareConfig, bool) {
+	if record.AccountID == "" {
+		return config, true
+	}
+	account, ok := accounts[record.AccountID]
+	return account, ok
+}
+
+// reconcilePendingUploads polls Cloudflare for every pending upload in the
+// account it was created in. Uploads past their URL's expiry for which
+// Cloudflare never received a file are marked expired; other failures to fetch
+// the video leave the upload pending for the next pass.
+func reconcilePendingUploads(ctx context.Context, config CloudflareConfig, accounts map[string]CloudflareConfig, store VideoStore) {
+	for _, record := range store.List() {
+		if record.UploadState != uploadPending {
+			continue
+		}
+
+		account, ok := recordAccount(record, config, accounts)
+		if !ok {
+			fmt.Printf("Could not reconcile upload %s: account %s is no longer configured\n", record.UID, record.AccountID)
+			continue
+		}
+
+		video, err := fetchVideo(withAccount(ctx, account), config, record.UID)
+		if err != nil {
+			fmt.Printf("Could not reconcile upload %s: %v\n", record.UID, err)
+			continue
+		}
+		if time.Now().After(record.UploadExpiresAt) && uploadNeverArrived(video) {
+			if expirePendingUpload(store, record.UID) {
+				fmt.Printf("Direct upload %s expired without a video\n", record.UID)
+			}
+			continue
+		}
+		if !video.Success {
+			continue
+		}
+
+		if state := resolveUploadState(store, video.Result); state != "" {
+			fmt.Printf("Direct upload %s is now %s\n", record.UID, state)
+		}
+	}
+}
+
+// runUploadReconciler reconciles pending uploads on every interval until ctx is cancelled
+func runUploadReconciler(ctx context.Context, config CloudflareConfig, accounts map[string]CloudflareConfig, store VideoStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reconcilePendingUploads(ctx, config, accounts, store)
+		}
+	}
+}
+
+func registerUploadTrackingRoutes(app *fiber.App, config CloudflareConfig, store VideoStore, timeout fiber.Handler) {
+	// Direct uploads started browser-side in the caller's account that haven't
+	// finished processing
+	app.Get("/api/uploads/pending", timeout, func(c *fiber.Ctx) error {
+		accountID := accountConfig(c.UserContext(), config).AccountID
+		pending := []PendingUpload{}
+		for _, record := range store.List() {
+			if record.UploadState != uploadPending {
+				continue
+			}
+			// Records made before accounts were tracked belong to the default account
+			owner := record.AccountID
+			if owner == "" {
+				owner = config.AccountID
+			}
+			if owner != accountID {
+				continue
+			}
+			pending = append(pending, PendingUpload{
+				UID:       record.UID,
+				State:     record.UploadState,
+				CreatedAt: record.UploadCreatedAt,
+				ExpiresAt: record.UploadExpiresAt,
+			})
+		}
+		sort.Slice(pending, func(i, j int) bool {
+			return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+		})
+
+		return respond(c, 200, fiber.Map{
+			"uploads": pending,
+		})
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReconcilePendingUploadsExpiry(t *testing.T) {
+	config := newFakeCloudflare(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uid := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		switch {
+		case uid == "gone":
+			w.WriteHeader(404)
+			fmt.Fprint(w, `{"success":false,"errors":[{"code":10003,"message":"not found"}],"messages":[],"result":null}`)
+		case uid == "flaky":
+			w.WriteHeader(500)
+			fmt.Fprint(w, `{"success":false,"errors":[{"code":10002,"message":"internal error"}],"messages":[],"result":null}`)
+		default:
+			state, _, _ := strings.Cut(uid, "-")
+			fmt.Fprintf(w, `{"success":true,"errors":[],"messages":[],"result":{"uid":%q,"readyToStream":%t,"status":{"state":%q}}}`, uid, state == "ready", state)
+		}
+	}))
+
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	cases := map[string]struct {
+		expiresAt time.Time
+		want      string
+	}{
+		"gone":                 {past, uploadExpired},
+		"pendingupload-late":   {past, uploadExpired},
+		"pendingupload-recent": {future, uploadPending},
+		"flaky":                {past, uploadPending},
+		"inprogress-late":      {past, uploadPending},
+		"ready-late":           {past, uploadReady},
+		"error-late":           {past, uploadError},
+	}
+	store := newMemoryVideoStore()
+	for uid, c := range cases {
+		trackPendingUpload(store, "acc", uid, c.expiresAt)
+	}
+
+	reconcilePendingUploads(context.Background(), config, map[string]CloudflareConfig{"acc": config}, store)
+
+	for uid, c := range cases {
+		record, _ := store.Get(uid)
+		if record.UploadState != c.want {
+			t.Errorf("%s: state = %q, want %q", uid, record.UploadState, c.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// deliveredMinutesQuery sums minutes viewed across the account for a date window
+const deliveredMinutesQuery = `query StreamMinutesViewed($accountTag: string!, $start: Date!, $end: Date!) {
+  viewer {
+    accounts(filter: {accountTag: $accountTag}) {
+      streamMinutesViewedAdaptiveGroups(filter: {date_geq: $start, date_lt: $end}, limit: 1) {
+        sum {
+          minutesViewed
+        }
+      }
+    }
+  }
+}`
+
+// StorageUsageResponse represents Cloudflare's stream storage-usage response
+type StorageUsageResponse struct {
+	Result struct {
+		TotalStorageMinutes      float64 `json:"totalStorageMinutes"`
+		TotalStorageMinutesLimit float64 `json:"totalStorageMinutesLimit"`
+		VideoCount               int     `json:"videoCount"`
+	} `json:"result"`
+	Success  bool              `json:"success"`
+	Errors   []CloudflareError `json:"errors"`
+	Messages []string          `json:"messages"`
+}
+
+// AccountUsage is the usage summary returned to clients
+type AccountUsage struct {
+	StoredMinutes      float64   `json:"storedMinutes"`
+	StoredMinutesLimit float64   `json:"storedMinutesLimit"`
+	VideoCount         int       `json:"videoCount"`
+	DeliveredMinutes   *float64  `json:"deliveredMinutes"`
+	DeliveredSince     string    `json:"deliveredSince"`
+	FetchedAt          time.Time `json:"fetchedAt"`
+}
+
+// usageCache holds the most recent usage summary per account for a fixed TTL.
+// The lock is never held across a fetch: concurrent misses for one account
+// share a single fetch, and other accounts are served meanwhile.
+type usageCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	entries  map[string]cachedUsage
+	inFlight map[string]*usageFetch
+}
+
+type cachedUsage struct {
+	value     *AccountUsage
+	expiresAt time.Time
+}
+
+// usageFetch is a fetch in progress; value and err are set before done closes
+type usageFetch struct {
+	done  chan struct{}
+	value *AccountUsage
+	err   error
+}
+
+func newUsageCache(ttl time.Duration) *usageCache {
+	return &usageCache{
+		ttl:      ttl,
+		entries:  make(map[string]cachedUsage),
+		inFlight: make(map[string]*usageFetch),
+	}
+}
+
+func (u *usageCache) get(accountID string, fetch func() (*AccountUsage, error)) (*AccountUsage, error) {
+	u.mu.Lock()
+	if entry, ok := u.entries[accountID]; ok && time.Now().Before(entry.expiresAt) {
+		u.mu.Unlock()
+		return entry.value, nil
+	}
+	if call, ok := u.inFlight[accountID]; ok {
+		u.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &usageFetch{done: make(chan struct{})}
+	u.inFlight[accountID] = call
+	u.mu.Unlock()
+
+	call.value, call.err = fetch()
+
+	u.mu.Lock()
+	delete(u.inFlight, accountID)
+	if call.err == nil {
+		u.entries[accountID] = cachedUsage{value: call.value, expiresAt: time.Now().Add(u.ttl)}
+	}
+	u.mu.Unlock()
+	close(call.done)
+	return call.value, call.err
+}
+
+// fetchStorageUsage reads stored minutes and video count for the account
+func fetchStorageUsage(ctx context.Context, config CloudflareConfig) (*StorageUsageResponse, error) {
+	result, err := callCloudflare[StorageUsageResponse](ctx, config, "GET", "/stream/storage-usage", nil)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("storage usage request failed: %v", result.Errors)
+	}
+
+	return result, nil
+}
+
+// fetchDeliveredMinutes queries the GraphQL analytics API for minutes viewed since start
+func fetchDeliveredMinutes(ctx context.Context, config CloudflareConfig, start, end time.Time) (float64, error) {
+	config = accountConfig(ctx, config)
+	reqBody, err := json.Marshal(fiber.Map{
+		"query": deliveredMinutesQuery,
+		"variables": fiber.Map{
+			"accountTag": config.AccountID,
+			"start":      start.Format("2006-01-02"),
+			"end":        end.Format("2006-01-02"),
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", config.BaseURL+"/graphql", bytes.NewReader(reqBody))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+config.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data struct {
+			Viewer struct {
+				Accounts []struct {
+					Groups []struct {
+						Sum struct {
+							MinutesViewed float64 `json:"minutesViewed"`
+						} `json:"sum"`
+					} `json:"streamMinutesViewedAdaptiveGroups"`
+				} `json:"accounts"`
+			} `json:"viewer"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	if len(result.Errors) > 0 {
+		return 0, errors.New(result.Errors[0].Message)
+	}
+
+	total := 0.0
+	for _, account := range result.Data.Viewer.Accounts {
+		for _, group := range account.Groups {
+			total += group.Sum.MinutesViewed
+		}
+	}
+	return total, nil
+}
+
+func registerUsageRoutes(app *fiber.App, config CloudflareConfig, ttl time.Duration, timeout fiber.Handler) {
+	cache := newUsageCache(ttl)
+
+	// Stored and delivered minutes for the account, cached since it changes slowly
+	app.Get("/api/account/usage", timeout, func(c *fiber.Ctx) error {
+		usage, err := cache.get(accountConfig(c.UserContext(), config).AccountID, func() (*AccountUsage, error) {
+			storage, err := fetchStorageUsage(c.UserContext(), config)
+			if err != nil {
+				return nil, err
+			}
+
+			// Delivered minutes are reported for the current calendar month
+			now := time.Now().UTC()
+			monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+			usage := &AccountUsage{
+				StoredMinutes:      storage.Result.TotalStorageMinutes,
+				StoredMinutesLimit: storage.Result.TotalStorageMinutesLimit,
+				VideoCount:         storage.Result.VideoCount,
+				DeliveredSince:     monthStart.Format("2006-01-02"),
+				FetchedAt:          now,
+			}
+
+			delivered, err := fetchDeliveredMinutes(c.UserContext(), config, monthStart, now.AddDate(0, 0, 1))
+			if err != nil {
+				fmt.Printf("Delivered minutes query error: %v\n", err)
+			} else {
+				usage.DeliveredMinutes = &delivered
+			}
+
+			return usage, nil
+		})
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get account usage",
+				"details": err.Error(),
+			})
+		}
+
+		return respond(c, 200, usage)
+	})
+}
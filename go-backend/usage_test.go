@@ -0,0 +1,68 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUsageCacheFetchDoesNotBlockOtherAccounts(t *testing.T) {
+	cache := newUsageCache(time.Minute)
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	go cache.get("slow", func() (*AccountUsage, error) {
+		close(started)
+		<-release
+		return &AccountUsage{VideoCount: 1}, nil
+	})
+	<-started
+	defer close(release)
+
+	done := make(chan struct{})
+	go func() {
+		cache.get("fast", func() (*AccountUsage, error) {
+			return &AccountUsage{VideoCount: 2}, nil
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a fetch for one account blocked another account's lookup")
+	}
+}
+
+func TestUsageCacheSharesConcurrentFetches(t *testing.T) {
+	cache := newUsageCache(time.Minute)
+	var fetches atomic.Int64
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make([]*AccountUsage, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = cache.get("acc", func() (*AccountUsage, error) {
+				fetches.Add(1)
+				<-release
+				return &AccountUsage{VideoCount: 3}, nil
+			})
+		}(i)
+	}
+	// Let every caller reach the cache before the fetch finishes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("%d fetches for concurrent misses, want 1", n)
+	}
+	for i, usage := range results {
+		if usage == nil || usage.VideoCount != 3 {
+			t.Errorf("caller %d got %+v", i, usage)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// validate checks request structs against their `validate` tags, reporting
+// fields by their JSON names
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// FieldError is one field-level validation failure returned to clients
+type FieldError struct {
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+// describeFieldError turns a validator failure into a short human-readable rule
+func describeFieldError(fe validator.FieldError) string {
+	param := fe.Param()
+	isString := fe.Kind() == reflect.String
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "gte", "min":
+		if isString {
+			return "must be at least " + param + " characters"
+		}
+		if fe.Kind() == reflect.Map || fe.Kind() == reflect.Slice {
+			return "must have at least " + param + " entries"
+		}
+		return "must be >= " + param
+	case "lte", "max":
+		if isString {
+			return "must be at most " + param + " characters"
+		}
+		if fe.Kind() == reflect.Map || fe.Kind() == reflect.Slice {
+			return "must have at most " + param + " entries"
+		}
+		return "must be <= " + param
+	case "gt":
+		return "must be > " + param
+	case "lt":
+		return "must be < " + param
+	case "gtfield":
+		return "must be greater than " + lowerFirst(param)
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(param, " ", ", ")
+	case "http_url", "url":
+		return "must be an absolute http(s) URL"
+	case "hexcolor":
+		return "must be a hex color such as #ff8800"
+	}
+	return "failed the " + fe.Tag() + " rule"
+}
+
+// lowerFirst converts a Go field name such as StartTimeSeconds to its JSON form
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// respondFieldErrors writes the standard 422 body for field-level failures
+func respondFieldErrors(c *fiber.Ctx, fields []FieldError) error {
+	return c.Status(422).JSON(fiber.Map{
+		"error":  "Validation failed",
+		"fields": fields,
+	})
+}
+
+// describeBodyError explains why a JSON body couldn't be decoded in terms of the
+// request rather than Go types
+func describeBodyError(err error) string {
+	var syntax *json.SyntaxError
+	var mismatch *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		return fmt.Sprintf("malformed JSON at byte %d: %v", syntax.Offset, syntax)
+	case errors.As(err, &mismatch):
+		if mismatch.Field == "" {
+			return fmt.Sprintf("body must be a JSON %s", jsonTypeName(mismatch.Type))
+		}
+		return fmt.Sprintf("%s must be a JSON %s, got %s", mismatch.Field, jsonTypeName(mismatch.Type), mismatch.Value)
+	}
+	return err.Error()
+}
+
+// jsonTypeName is the JSON name for the kind of value a Go type decodes from
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Ptr:
+		return jsonTypeName(t.Elem())
+	}
+	return "object"
+}
+
+// bindBody parses the JSON body into out and checks its validate tags. On failure
+// it writes a 400 (unparseable body) or 422 (constraint violations) and returns false.
+func bindBody(c *fiber.Ctx, out interface{}) (bool, error) {
+	if err := c.BodyParser(out); err != nil {
+		return false, c.Status(400).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": describeBodyError(err),
+		})
+	}
+
+	err := validate.Struct(out)
+	if err == nil {
+		return true, nil
+	}
+
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return false, c.Status(500).JSON(fiber.Map{
+			"error":   "Could not validate request",
+			"details": err.Error(),
+		})
+	}
+
+	fields := make([]FieldError, 0, len(invalid))
+	for _, fe := range invalid {
+		// Namespace is "Struct.field.sub"; drop the struct name
+		_, field, _ := strings.Cut(fe.Namespace(), ".")
+		fields = append(fields, FieldError{Field: field, Error: describeFieldError(fe)})
+	}
+	return false, respondFieldErrors(c, fields)
+}
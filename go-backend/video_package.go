@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PackageSection is one part of a combined video package. A failed section
+// carries its error instead of data so the rest of the package still renders.
+type PackageSection struct {
+	OK     bool              `json:"ok"`
+	Data   interface{}       `json:"data,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Errors []CloudflareError `json:"errors,omitempty"`
+}
+
+// packageFetch loads one section of a video package
+type packageFetch func(ctx context.Context) PackageSection
+
+// failedSection reports a sub-call that errored or that Cloudflare refused
+func failedSection(message string, err error, errs []CloudflareError) PackageSection {
+	if err != nil {
+		message = message + ": " + err.Error()
+	}
+	return PackageSection{Error: message, Errors: errs}
+}
+
+// fetchPackage runs every section's fetch with at most concurrency in flight
+func fetchPackage(ctx context.Context, fetches map[string]packageFetch, concurrency int) map[string]PackageSection {
+	sections := make(map[string]PackageSection, len(fetches))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+
+	for name, fetch := range fetches {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(name string, fetch packageFetch) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			section := fetch(ctx)
+			mu.Lock()
+			sections[name] = section
+			mu.Unlock()
+		}(name, fetch)
+	}
+
+	wg.Wait()
+	return sections
+}
+
+func registerPackageRoutes(app *fiber.App, config CloudflareConfig, concurrency int, timeout fiber.Handler) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// Everything a video detail page needs in one call: status, captions and downloads
+	app.Get("/api/video/:uid/full", timeout, func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+
+		sections := fetchPackage(c.UserContext(), map[string]packageFetch{
+			"status": func(ctx context.Context) PackageSection {
+				video, err := fetchVideo(ctx, config, uid)
+				if err != nil || !video.Success {
+					var errs []CloudflareError
+					if video != nil {
+						errs = video.Errors
+					}
+					return failedSection("Failed to get video status", err, errs)
+				}
+				dto := newVideoDTO(video.Result)
+				if urls, err := playbackURLs(ctx, config, video.Result); err == nil {
+					dto.URLs = &urls
+				}
+				return PackageSection{OK: true, Data: dto}
+			},
+			"captions": func(ctx context.Context) PackageSection {
+				captions, err := listCaptions(ctx, config, uid)
+				if err != nil || !captions.Success {
+					var errs []CloudflareError
+					if captions != nil {
+						errs = captions.Errors
+					}
+					return failedSection("Failed to list captions", err, errs)
+				}
+				return PackageSection{OK: true, Data: captions.Result}
+			},
+			"downloads": func(ctx context.Context) PackageSection {
+				downloads, err := callCloudflare[DownloadsResponse](ctx, config, "GET", "/stream/"+uid+"/downloads", nil)
+				if err != nil || !downloads.Success {
+					var errs []CloudflareError
+					if downloads != nil {
+						errs = downloads.Errors
+					}
+					return failedSection("Failed to get downloads", err, errs)
+				}
+				return PackageSection{OK: true, Data: downloads.Result}
+			},
+		}, concurrency)
+
+		// Partial packages are still useful; only fail when nothing could be loaded
+		complete := true
+		anyOK := false
+		for _, section := range sections {
+			complete = complete && section.OK
+			anyOK = anyOK || section.OK
+		}
+		if !anyOK {
+			return c.Status(502).JSON(fiber.Map{
+				"error":    "Could not load any part of the video",
+				"sections": sections,
+			})
+		}
+
+		return respond(c, 200, fiber.Map{
+			"uid":       uid,
+			"complete":  complete,
+			"status":    sections["status"],
+			"captions":  sections["captions"],
+			"downloads": sections["downloads"],
+		})
+	})
+}
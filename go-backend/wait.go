@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WaitConfig bounds the long-poll wait endpoint
+type WaitConfig struct {
+	PollInterval time.Duration
+	MaxWait      time.Duration
+}
+
+// waitUntilSettled polls a video until it is ready to stream or has failed,
+// returning the last result seen. It stops as soon as ctx ends, including the
+// Cloudflare call in flight, and reports ctx's error in that case.
+func waitUntilSettled(ctx context.Context, config CloudflareConfig, uid string, interval time.Duration) (*VideoUploadResponse, error) {
+	var last *VideoUploadResponse
+	for {
+		result, err := fetchVideo(ctx, config, uid)
+		if ctx.Err() != nil {
+			return last, ctx.Err()
+		}
+		if err != nil {
+			return last, err
+		}
+		last = result
+		if !result.Success || result.Result.ReadyToStream || result.Result.Status.State == "error" {
+			return last, nil
+		}
+
+		if !sleepContext(ctx, interval) {
+			return last, ctx.Err()
+		}
+	}
+}
+
+func registerWaitRoutes(app *fiber.App, config CloudflareConfig, cfg WaitConfig) {
+	// Long-poll until a video is ready or failed, or ?timeout= (default and cap
+	// MaxWait) passes. A timeout answers 200 with the latest state and timedOut set.
+	app.Get("/api/video/:uid/wait", func(c *fiber.Ctx) error {
+		uid := c.Params("uid")
+		wait := cfg.MaxWait
+		if value := c.Query("timeout"); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed <= 0 {
+				return c.Status(400).JSON(fiber.Map{
+					"error": "timeout must be a positive duration such as 30s",
+				})
+			}
+			wait = min(parsed, cfg.MaxWait)
+		}
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), wait)
+		defer cancel()
+
+		// Stop polling, and abort the call in flight, as soon as the client
+		// disconnects so the worker is freed straight away
+		go watchClient(ctx, c.Context().Done(), c.Context().Conn(), cancel)
+
+		start := time.Now()
+		result, err := waitUntilSettled(ctx, config, uid, cfg.PollInterval)
+		timedOut := errors.Is(err, context.DeadlineExceeded)
+		switch {
+		case errors.Is(err, context.Canceled):
+			fmt.Printf("Wait for %s cancelled after %s\n", uid, time.Since(start).Round(time.Millisecond))
+			return nil
+		case err != nil && !timedOut:
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to get video status",
+				"details": err.Error(),
+			})
+		case result == nil:
+			// The deadline passed before Cloudflare answered even once
+			return c.Status(504).JSON(fiber.Map{
+				"error":   "Request timed out",
+				"details": fmt.Sprintf("no status within %s", wait),
+			})
+		case !result.Success:
+			return respondCloudflareError(c, "Failed to get video status", result.Errors)
+		}
+
+		meta := cloudflareMeta(result.Success, result.Errors, result.Messages)
+		meta["timedOut"] = timedOut
+		meta["waitedMs"] = time.Since(start).Milliseconds()
+		return respondResult(c, 200, newVideoDTO(result.Result), meta)
+	})
+}
@@ -0,0 +1,65 @@
+//go:build linux || darwin || freebsd
+
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestWaitStopsPollingWhenClientDisconnects(t *testing.T) {
+	// Cloudflare keeps reporting the video as still encoding
+	var polls atomic.Int64
+	config := newFakeCloudflare(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls.Add(1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"errors":   []interface{}{},
+			"messages": []interface{}{},
+			"result":   map[string]interface{}{"uid": "abc", "status": map[string]string{"state": "inprogress"}},
+		})
+	}))
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	registerWaitRoutes(app, config, WaitConfig{PollInterval: 10 * time.Millisecond, MaxWait: time.Minute})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go app.Listener(ln)
+	defer app.Shutdown()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	if _, err := conn.Write([]byte("GET /api/video/abc/wait?timeout=1m HTTP/1.1\r\nHost: test\r\n\r\n")); err != nil {
+		t.Fatalf("write request: %v", err)
+	}
+
+	// Hang up once the wait loop is clearly polling
+	deadline := time.Now().Add(2 * time.Second)
+	for polls.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if polls.Load() < 3 {
+		t.Fatalf("wait loop made %d polls before the disconnect", polls.Load())
+	}
+	conn.Close()
+
+	// The watcher probes every clientGoneInterval; allow a few of those, then
+	// polling must have stopped for good
+	time.Sleep(4 * clientGoneInterval)
+	stopped := polls.Load()
+	time.Sleep(2 * clientGoneInterval)
+	if after := polls.Load(); after != stopped {
+		t.Errorf("wait loop kept polling after the client left: %d polls became %d", stopped, after)
+	}
+}
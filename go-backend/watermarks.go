@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// pngSignature is the 8-byte magic number every PNG file starts with
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+
+// watermarkPositions are the placements Cloudflare accepts for a watermark
+var watermarkPositions = map[string]bool{
+	"upperRight": true,
+	"upperLeft":  true,
+	"lowerLeft":  true,
+	"lowerRight": true,
+	"center":     true,
+}
+
+// WatermarkResponse represents Cloudflare's response when creating a watermark profile
+type WatermarkResponse struct {
+	Result struct {
+		UID      string  `json:"uid"`
+		Name     string  `json:"name"`
+		Opacity  float64 `json:"opacity"`
+		Padding  float64 `json:"padding"`
+		Scale    float64 `json:"scale"`
+		Position string  `json:"position"`
+	} `json:"result"`
+	Success  bool              `json:"success"`
+	Errors   []CloudflareError `json:"errors"`
+	Messages []string          `json:"messages"`
+}
+
+// parseUnitFormValue reads an optional form value that Cloudflare requires to be within 0.0-1.0
+func parseUnitFormValue(c *fiber.Ctx, key string) (string, error) {
+	value := c.FormValue(key)
+	if value == "" {
+		return "", nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 || f > 1 {
+		return "", fmt.Errorf("%s must be a number between 0.0 and 1.0", key)
+	}
+	return value, nil
+}
+
+func registerWatermarkRoutes(app *fiber.App, config CloudflareConfig, timeout fiber.Handler) {
+	// Create a watermark profile from an uploaded PNG
+	app.Post("/api/watermarks", timeout, func(c *fiber.Ctx) error {
+		file, err := c.FormFile("file")
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "No watermark image provided",
+				"details": err.Error(),
+			})
+		}
+
+		fields := map[string]string{}
+		for _, key := range []string{"opacity", "padding", "scale"} {
+			value, err := parseUnitFormValue(c, key)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{
+					"error":   "Invalid watermark parameter",
+					"details": err.Error(),
+				})
+			}
+			if value != "" {
+				fields[key] = value
+			}
+		}
+
+		if position := c.FormValue("position"); position != "" {
+			if !watermarkPositions[position] {
+				return c.Status(400).JSON(fiber.Map{
+					"error":   "Invalid watermark parameter",
+					"details": "position must be one of upperRight, upperLeft, lowerLeft, lowerRight, center",
+				})
+			}
+			fields["position"] = position
+		}
+		if name := c.FormValue("name"); name != "" {
+			fields["name"] = name
+		}
+
+		fileContent, err := file.Open()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not open file",
+				"details": err.Error(),
+			})
+		}
+		defer fileContent.Close()
+
+		image, err := io.ReadAll(fileContent)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not read file",
+				"details": err.Error(),
+			})
+		}
+
+		if !bytes.HasPrefix(image, pngSignature) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Watermark image must be a PNG",
+			})
+		}
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		for key, value := range fields {
+			if err := writer.WriteField(key, value); err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":   "Could not create form field",
+					"details": err.Error(),
+				})
+			}
+		}
+		part, err := writer.CreateFormFile("file", file.Filename)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not create form file",
+				"details": err.Error(),
+			})
+		}
+		if _, err := part.Write(image); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not copy file content",
+				"details": err.Error(),
+			})
+		}
+		writer.Close()
+
+		req, err := newCloudflareRequest(c.UserContext(), config, "POST", "/stream/watermarks", body)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not create request",
+				"details": err.Error(),
+			})
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Failed to create watermark",
+				"details": err.Error(),
+			})
+		}
+		defer resp.Body.Close()
+
+		var result WatermarkResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "Could not parse response",
+				"details": err.Error(),
+			})
+		}
+
+		if !result.Success {
+			return respondCloudflareError(c, "Watermark creation failed", result.Errors)
+		}
+
+		return respond(c, 201, result.Result)
+	})
+}
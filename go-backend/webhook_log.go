@@ -0,0 +1,58 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// WebhookEvent is a summary of one webhook delivery, kept for debugging
+type WebhookEvent struct {
+	ReceivedAt     time.Time `json:"receivedAt"`
+	UID            string    `json:"uid"`
+	Type           string    `json:"type"`
+	SignatureValid bool      `json:"signatureValid"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// WebhookLog is a fixed-size ring buffer of the most recent webhook deliveries
+type WebhookLog struct {
+	mu     sync.Mutex
+	events []WebhookEvent
+	next   int
+	full   bool
+}
+
+func newWebhookLog(size int) *WebhookLog {
+	if size < 1 {
+		size = 1
+	}
+	return &WebhookLog{events: make([]WebhookEvent, size)}
+}
+
+// Add records an event, overwriting the oldest once the buffer is full
+func (l *WebhookLog) Add(event WebhookEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events[l.next] = event
+	l.next = (l.next + 1) % len(l.events)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Recent returns the recorded events, newest first
+func (l *WebhookLog) Recent() []WebhookEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	count := l.next
+	if l.full {
+		count = len(l.events)
+	}
+	recent := make([]WebhookEvent, 0, count)
+	for i := 1; i <= count; i++ {
+		recent = append(recent, l.events[(l.next-i+len(l.events))%len(l.events)])
+	}
+	return recent
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// webhookMaxSkew bounds how old a signed webhook may be before it is rejected
+const webhookMaxSkew = 5 * time.Minute
+
+// verifyWebhookSignature checks Cloudflare's Webhook-Signature header,
+// formatted as "time=<unix>,sig1=<hex hmac-sha256 of time.body>".
+func verifyWebhookSignature(secret, header string, body []byte) bool {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "time":
+			timestamp = value
+		case "sig1":
+			signature = value
+		}
+	}
+	if timestamp == "" || signature == "" {
+		return false
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > webhookMaxSkew || age < -webhookMaxSkew {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func registerWebhookRoutes(app *fiber.App, secret string, hub *VideoHub, events *WebhookLog, store VideoStore, prewarmer *ThumbnailPrewarmer, timeout fiber.Handler) {
+	if secret == "" {
+		fmt.Println("Warning: CLOUDFLARE_WEBHOOK_SECRET not set, webhook signatures will not be verified")
+	}
+
+	// Cloudflare Stream webhook receiver
+	app.Post("/api/webhooks/cloudflare", timeout, func(c *fiber.Ctx) error {
+		body := c.Body()
+		event := WebhookEvent{ReceivedAt: time.Now().UTC()}
+		defer func() { events.Add(event) }()
+
+		// Parse before verifying so rejected deliveries still show what was sent
+		var result CloudflareResult
+		parseErr := json.Unmarshal(body, &result)
+		event.UID = result.UID
+		event.Type = result.Status.State
+
+		event.SignatureValid = secret == "" || verifyWebhookSignature(secret, c.Get("Webhook-Signature"), body)
+		if !event.SignatureValid {
+			event.Error = "invalid signature"
+			return c.Status(401).JSON(fiber.Map{
+				"error": "Invalid webhook signature",
+			})
+		}
+
+		if parseErr != nil {
+			event.Error = parseErr.Error()
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "Could not parse webhook payload",
+				"details": parseErr.Error(),
+			})
+		}
+
+		if result.UID == "" {
+			event.Error = "missing uid"
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Webhook payload missing uid",
+			})
+		}
+
+		delivered := hub.Publish(result.UID, result)
+		fmt.Printf("Webhook for %s (state: %s) delivered to %d subscriber(s)\n",
+			result.UID, result.Status.State, delivered)
+
+		if state := resolveUploadState(store, result); state != "" {
+			fmt.Printf("Direct upload %s is now %s\n", result.UID, state)
+		}
+
+		if prewarmer != nil {
+			prewarmer.Trigger(c.UserContext(), result)
+		}
+
+		return c.SendStatus(204)
+	})
+
+	// Recent deliveries, newest first, for debugging missing notifications
+	app.Get("/api/webhooks/events", timeout, func(c *fiber.Ctx) error {
+		return respond(c, 200, fiber.Map{
+			"events": events.Recent(),
+		})
+	})
+}